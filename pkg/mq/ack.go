@@ -0,0 +1,446 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+// ErrUnknownDelivery is returned when a delivery is settled after it was already acknowledged,
+// rejected or redelivered because its visibility timeout expired
+var ErrUnknownDelivery = errors.New("mq: unknown delivery")
+
+// DefaultVisibilityTimeout is the time a delivery has to be settled before it is redelivered
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// Headers set on the messages republished to a dead-letter topic
+const (
+	// HeaderDeadLetterReason is why the message was dead-lettered, one of the DeadLetter reasons
+	HeaderDeadLetterReason = "x-dead-letter-reason"
+
+	// HeaderDeliveryAttempts is the number of times the message was delivered
+	HeaderDeliveryAttempts = "x-delivery-attempts"
+
+	// HeaderOriginalTopic is the topic the message was originally published to
+	HeaderOriginalTopic = "x-original-topic"
+)
+
+// Reasons for dead-lettering a message
+const (
+	// DeadLetterRejected is used when a delivery was rejected with Nack(false)
+	DeadLetterRejected = "rejected"
+
+	// DeadLetterMaxDeliveries is used when a message was delivered the maximum number of times without being acknowledged
+	DeadLetterMaxDeliveries = "max-deliveries"
+
+	// DeadLetterExpired is used when the TTL of a message elapsed before it was polled
+	DeadLetterExpired = "expired"
+
+	// DeadLetterClosed is used when a message could not be requeued because its subscription is closed
+	DeadLetterClosed = "closed"
+)
+
+// AckOption configures a subscription created by Broker.SubscribeAck
+type AckOption func(*ackOptions)
+
+// ackOptions holds the configuration of an acknowledged subscription
+type ackOptions struct {
+	visibilityTimeout time.Duration
+	maxDeliveries     int
+	deadLetterTopic   string
+	queueOptions      []queue.Option
+}
+
+// WithVisibilityTimeout sets the time a delivery has to be settled before the message is redelivered
+func WithVisibilityTimeout(d time.Duration) AckOption {
+	return func(o *ackOptions) {
+		if d > 0 {
+			o.visibilityTimeout = d
+		}
+	}
+}
+
+// WithMaxDeliveries limits the number of times a message is delivered
+// Once a message reaching the limit is rejected or not settled in time, it is sent to the dead-letter topic if any and discarded otherwise
+// A limit less than or equal to 0 means a message is redelivered until it is settled, which is the default
+func WithMaxDeliveries(n int) AckOption {
+	return func(o *ackOptions) {
+		o.maxDeliveries = n
+	}
+}
+
+// WithDeadLetterTopic republishes the messages rejected with Nack(false), exceeding the maximum deliveries or expired to the topic
+// The republished message keeps its ID, timestamp and headers, and gains the dead-letter headers
+func WithDeadLetterTopic(topic string) AckOption {
+	return func(o *ackOptions) {
+		o.deadLetterTopic = topic
+	}
+}
+
+// WithQueueOptions sets the options of the queue backing the subscription
+func WithQueueOptions(opts ...queue.Option) AckOption {
+	return func(o *ackOptions) {
+		o.queueOptions = append(o.queueOptions, opts...)
+	}
+}
+
+// Delivery is a message handed out by an AckSubscription
+// It must be settled with Ack or Nack before the visibility timeout expires, otherwise it is redelivered.
+type Delivery[T any] struct {
+	Message[T]
+
+	// Attempt is the number of times the message has been delivered, starting at 1
+	Attempt int
+
+	tag uint64
+	sub *ackSubscription[T]
+}
+
+// Ack acknowledges the delivery, the message will not be delivered again
+func (d Delivery[T]) Ack() error {
+	if d.sub == nil {
+		return ErrUnknownDelivery
+	}
+
+	if _, err := d.sub.take(d.tag); err != nil {
+		return err
+	}
+
+	d.sub.notify()
+	return nil
+}
+
+// Nack rejects the delivery, the message is delivered again if requeue is true and dead-lettered otherwise
+// A requeued message goes back even if the subscription is full, it returns queue.ErrClosed
+// if the subscription is closed, the message is then dead-lettered.
+// It returns ErrBrokerClosed if the message can not reach the dead-letter topic because the broker is draining or closed,
+// Shutdown then hands the message back.
+func (d Delivery[T]) Nack(requeue bool) error {
+	if d.sub == nil {
+		return ErrUnknownDelivery
+	}
+
+	env, err := d.sub.take(d.tag)
+	if err != nil {
+		return err
+	}
+
+	defer d.sub.notify()
+	return d.sub.reject(env, requeue)
+}
+
+// AckSubscription is a subscription delivering each message at least once
+// Every delivery must be settled with Ack or Nack, unsettled deliveries are redelivered after the visibility timeout.
+type AckSubscription[T any] interface {
+
+	// Poll reads a delivery from the subscription.
+	// It will wait till there is consumable data.
+	Poll() (Delivery[T], bool)
+
+	// PollContext works like Poll but returns ctx.Err() once the context is done
+	// and queue.ErrClosed if the subscription is closed.
+	PollContext(ctx context.Context) (Delivery[T], error)
+
+	// TryPoll reads a delivery from the subscription without blocking.
+	TryPoll() (delivery Delivery[T], ok bool, available bool)
+
+	// PollTimeout works like TryPoll but waits at most d for a delivery to become available.
+	PollTimeout(d time.Duration) (delivery Delivery[T], ok bool, available bool)
+
+	// Len returns the number of messages waiting to be delivered.
+	Len() int
+
+	// InFlight returns the number of deliveries waiting to be settled.
+	InFlight() int
+
+	// IsClosed reports whether the subscription is closed.
+	IsClosed() bool
+
+	// Stats returns a snapshot of the counters of the subscription.
+	Stats() queue.Stats
+
+	// ID returns the identifier of the subscription, unique within its broker.
+	ID() uint64
+
+	// OnExpiry calls fn with every message discarded because its TTL elapsed, before it is dead-lettered.
+	// fn may be called concurrently, a nil fn removes it.
+	OnExpiry(fn func(Message[T]))
+
+	// Unsubscribe removes the subscription from the broker and closes it, unsettled deliveries are discarded.
+	// If the timeOut is less than 0, then the subscription will be read-only.
+	Unsubscribe(timeOut time.Duration)
+}
+
+// inFlight is a delivery waiting to be settled
+type inFlight[T any] struct {
+	env   envelope[T]
+	timer *time.Timer
+}
+
+type ackSubscription[T any] struct {
+	*subscription[T]
+	options ackOptions
+
+	mu       sync.Mutex
+	nextTag  uint64
+	inFlight map[uint64]*inFlight[T]
+	closed   bool
+
+	// changed is closed once a delivery is settled or a message expires, it is created by the first waiter
+	changed chan struct{}
+
+	// deadLetters are the messages which could not be dead-lettered because the broker was stopping, Shutdown hands them back
+	deadLetters []Message[T]
+
+	// expiryHandler is set by OnExpiry
+	expiryHandler atomic.Pointer[func(Message[T])]
+}
+
+func newAckSubscription[T any](sub *subscription[T], o ackOptions) *ackSubscription[T] {
+	return &ackSubscription[T]{
+		subscription: sub,
+		options:      o,
+		inFlight:     make(map[uint64]*inFlight[T]),
+	}
+}
+
+// deliver records the envelope as in flight and returns its delivery
+func (s *ackSubscription[T]) deliver(env envelope[T]) Delivery[T] {
+	env.deliveries++
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextTag++
+	tag := s.nextTag
+	if !s.closed {
+		s.inFlight[tag] = &inFlight[T]{
+			env: env,
+			timer: time.AfterFunc(s.options.visibilityTimeout, func() {
+				if env, err := s.take(tag); err == nil {
+					s.reject(env, true)
+					s.notify()
+				}
+			}),
+		}
+	}
+
+	return Delivery[T]{Message: env.Message, Attempt: env.deliveries, tag: tag, sub: s}
+}
+
+// take removes the delivery from the in flight ones and returns its envelope
+func (s *ackSubscription[T]) take(tag uint64) (envelope[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.inFlight[tag]
+	if !ok {
+		return envelope[T]{}, ErrUnknownDelivery
+	}
+
+	delete(s.inFlight, tag)
+	f.timer.Stop()
+
+	return f.env, nil
+}
+
+// notify wakes up the goroutines waiting for the subscription to be settled
+// It is called once a delivery is settled, after a rejected message was requeued
+func (s *ackSubscription[T]) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// waitSettled waits until the subscription holds no message and every delivery is settled
+// The subscription must not receive new messages meanwhile, which holds once the broker is draining
+func (s *ackSubscription[T]) waitSettled(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if len(s.inFlight) == 0 && s.queue.Len() == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// takeDeadLetters returns the messages which could not be dead-lettered and forgets them
+func (s *ackSubscription[T]) takeDeadLetters() []Message[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadLetters := s.deadLetters
+	s.deadLetters = nil
+
+	return deadLetters
+}
+
+// abandon discards the deliveries in flight and returns their envelopes in delivery order, settling them afterwards fails
+func (s *ackSubscription[T]) abandon() []envelope[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := make([]uint64, 0, len(s.inFlight))
+	for tag := range s.inFlight {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i] < tags[j]
+	})
+
+	envs := make([]envelope[T], len(tags))
+	for i, tag := range tags {
+		f := s.inFlight[tag]
+		f.timer.Stop()
+		delete(s.inFlight, tag)
+		envs[i] = f.env
+	}
+	s.closed = true
+
+	return envs
+}
+
+// reject requeues the envelope, or dead-letters it when it must not or can not be delivered again
+func (s *ackSubscription[T]) reject(env envelope[T], requeue bool) error {
+	if !requeue {
+		return s.deadLetter(env, DeadLetterRejected)
+	}
+
+	if s.options.maxDeliveries > 0 && env.deliveries >= s.options.maxDeliveries {
+		return s.deadLetter(env, DeadLetterMaxDeliveries)
+	}
+
+	// The redelivery expires at the deadline the message got when first stored, whether its TTL is its own or the subscription's
+	if env.deadline != nil && !env.deadline.IsZero() && !time.Now().Before(*env.deadline) {
+		return s.expire(env)
+	}
+
+	// The message was admitted once already, it goes back even if the queue is full
+	if err := s.queue.Push(env, queue.Priority(env.Priority), queue.Deadline(env.deadline), queue.Requeue()); err != nil {
+		s.deadLetter(env, DeadLetterClosed)
+		return err
+	}
+
+	return nil
+}
+
+// expire passes the expired envelope to the expiry handler if any, then dead-letters it
+func (s *ackSubscription[T]) expire(env envelope[T]) error {
+	defer s.notify()
+
+	if handler := s.expiryHandler.Load(); handler != nil {
+		(*handler)(env.Message)
+	}
+	return s.deadLetter(env, DeadLetterExpired)
+}
+
+func (s *ackSubscription[T]) OnExpiry(fn func(Message[T])) {
+	if fn == nil {
+		s.expiryHandler.Store(nil)
+		return
+	}
+	s.expiryHandler.Store(&fn)
+}
+
+// deadLetter republishes the message to the dead-letter topic, or discards it if there is none
+// Once the broker is draining or closed, the dead-letter subscriptions are stopped as well,
+// the message is kept for Shutdown and ErrBrokerClosed returned.
+func (s *ackSubscription[T]) deadLetter(env envelope[T], reason string) error {
+	if s.options.deadLetterTopic == "" {
+		return nil
+	}
+
+	msg := env.Message
+	msg.Headers = make(map[string]string, len(env.Headers)+3)
+	for key, value := range env.Headers {
+		msg.Headers[key] = value
+	}
+	msg.Headers[HeaderDeadLetterReason] = reason
+	msg.Headers[HeaderDeliveryAttempts] = strconv.Itoa(env.deliveries)
+	msg.Headers[HeaderOriginalTopic] = env.Topic
+	msg.Topic = s.options.deadLetterTopic
+	// The dead-letter topic keeps the message until it is inspected
+	msg.TTL = 0
+
+	if _, err := s.broker.PublishMessageE(msg); err != nil {
+		s.mu.Lock()
+		s.deadLetters = append(s.deadLetters, msg)
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (s *ackSubscription[T]) Poll() (Delivery[T], bool) {
+	env, ok := s.queue.Poll()
+	if !ok {
+		return Delivery[T]{}, false
+	}
+	return s.deliver(env), true
+}
+
+func (s *ackSubscription[T]) PollContext(ctx context.Context) (Delivery[T], error) {
+	env, err := s.queue.PollContext(ctx)
+	if err != nil {
+		return Delivery[T]{}, err
+	}
+	return s.deliver(env), nil
+}
+
+func (s *ackSubscription[T]) TryPoll() (Delivery[T], bool, bool) {
+	env, ok, available := s.queue.TryPoll()
+	if !available {
+		return Delivery[T]{}, ok, false
+	}
+	return s.deliver(env), ok, true
+}
+
+func (s *ackSubscription[T]) PollTimeout(d time.Duration) (Delivery[T], bool, bool) {
+	env, ok, available := s.queue.PollTimeout(d)
+	if !available {
+		return Delivery[T]{}, ok, false
+	}
+	return s.deliver(env), ok, true
+}
+
+func (s *ackSubscription[T]) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.inFlight)
+}
+
+func (s *ackSubscription[T]) Unsubscribe(timeOut time.Duration) {
+	s.subscription.Unsubscribe(timeOut)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for tag, f := range s.inFlight {
+		f.timer.Stop()
+		delete(s.inFlight, tag)
+	}
+}
@@ -0,0 +1,443 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+func TestAckSubscriptionAck(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithVisibilityTimeout(10*time.Millisecond))
+	broker.Publish("test", 1)
+
+	delivery, ok := subscriber.Poll()
+	if !ok {
+		t.Fatal("Poll on available value should be True got False")
+	}
+	if delivery.Payload != 1 || delivery.Attempt != 1 || delivery.Topic != "test" {
+		t.Errorf("Invalid Delivery: %+v", delivery)
+	}
+	if inFlight := subscriber.InFlight(); inFlight != 1 {
+		t.Errorf("Invalid InFlight: Expected: 1 Obtained: %d", inFlight)
+	}
+
+	if err := delivery.Ack(); err != nil {
+		t.Errorf("Ack should succeed, Obtained: %v", err)
+	}
+	if err := delivery.Ack(); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrUnknownDelivery, err)
+	}
+	if inFlight := subscriber.InFlight(); inFlight != 0 {
+		t.Errorf("Invalid InFlight: Expected: 0 Obtained: %d", inFlight)
+	}
+
+	if _, _, available := subscriber.PollTimeout(30 * time.Millisecond); available {
+		t.Error("Acknowledged message should not be redelivered")
+	}
+}
+
+func TestAckSubscriptionNack(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"))
+	broker.Publish("test", 1)
+	broker.Publish("test", 2)
+
+	first, _ := subscriber.Poll()
+	if err := first.Nack(true); err != nil {
+		t.Errorf("Nack should succeed, Obtained: %v", err)
+	}
+
+	second, _ := subscriber.Poll()
+	if err := second.Nack(false); err != nil {
+		t.Errorf("Nack should succeed, Obtained: %v", err)
+	}
+
+	redelivered, _, available := subscriber.TryPoll()
+	if !available {
+		t.Fatal("Requeued message should be redelivered")
+	}
+	if redelivered.Payload != 1 || redelivered.Attempt != 2 || redelivered.ID != first.ID {
+		t.Errorf("Invalid Delivery: Expected: payload 1 attempt 2 Obtained: %+v", redelivered)
+	}
+	redelivered.Ack()
+
+	if _, _, available := subscriber.TryPoll(); available {
+		t.Error("Rejected message should not be redelivered")
+	}
+}
+
+func TestAckSubscriptionNackBounded(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithQueueOptions(queue.WithCapacity(1), queue.WithOverflowPolicy(queue.Reject)))
+	broker.Publish("test", 1)
+
+	first, _ := subscriber.Poll()
+	broker.Publish("test", 2)
+
+	if err := first.Nack(true); err != nil {
+		t.Errorf("Nack should succeed, Obtained: %v", err)
+	}
+
+	second, _, _ := subscriber.TryPoll()
+	redelivered, _, available := subscriber.TryPoll()
+	if !available || second.Payload != 2 || redelivered.Payload != 1 || redelivered.Attempt != 2 {
+		t.Fatalf("Invalid Deliveries: %+v %+v", second, redelivered)
+	}
+	second.Ack()
+
+	broker.Close(-1)
+	if err := redelivered.Nack(true); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", queue.ErrClosed, err)
+	}
+}
+
+func TestAckSubscriptionVisibilityTimeout(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithVisibilityTimeout(10*time.Millisecond))
+	broker.Publish("test", 1)
+
+	first, _ := subscriber.Poll()
+
+	redelivered, _, available := subscriber.PollTimeout(time.Second)
+	if !available {
+		t.Fatal("Unsettled message should be redelivered after the visibility timeout")
+	}
+	if redelivered.Payload != 1 || redelivered.Attempt != 2 {
+		t.Errorf("Invalid Delivery: Expected: payload 1 attempt 2 Obtained: %+v", redelivered)
+	}
+
+	if err := first.Ack(); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrUnknownDelivery, err)
+	}
+	if err := redelivered.Ack(); err != nil {
+		t.Errorf("Ack should succeed, Obtained: %v", err)
+	}
+}
+
+func TestAckSubscriptionUnsubscribe(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"))
+	broker.Publish("test", 1)
+
+	delivery, _ := subscriber.Poll()
+	subscriber.Unsubscribe(-1)
+
+	if err := delivery.Ack(); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrUnknownDelivery, err)
+	}
+	if _, ok := subscriber.Poll(); ok {
+		t.Error("Poll on unsubscribed subscription should be False")
+	}
+}
+
+func TestAckSubscriptionDeadLetter(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	deadLetters := broker.Subscribe(ExactMatcher("orders.dlq"))
+	subscriber := broker.SubscribeAck(
+		ExactMatcher("orders"),
+		WithMaxDeliveries(2),
+		WithDeadLetterTopic("orders.dlq"),
+		WithVisibilityTimeout(10*time.Millisecond),
+	)
+
+	broker.PublishMessage(Message[int]{Topic: "orders", Headers: map[string]string{"trace-id": "abc"}, Payload: 1})
+	broker.Publish("orders", 2)
+
+	// The first message fails twice and exceeds its deliveries
+	first, _ := subscriber.Poll()
+	first.Nack(true)
+	second, _ := subscriber.Poll()
+	if second.Payload != 2 {
+		t.Fatalf("Invalid Value: Expected: 2 Obtained: %v", second.Payload)
+	}
+	retry, _ := subscriber.Poll()
+	if retry.Payload != 1 || retry.Attempt != 2 {
+		t.Fatalf("Invalid Delivery: Expected: payload 1 attempt 2 Obtained: %+v", retry)
+	}
+	retry.Nack(true)
+
+	// The second message is rejected without requeue
+	second.Nack(false)
+
+	expected := []struct {
+		payload  int
+		reason   string
+		attempts string
+	}{
+		{payload: 1, reason: DeadLetterMaxDeliveries, attempts: "2"},
+		{payload: 2, reason: DeadLetterRejected, attempts: "1"},
+	}
+	for _, e := range expected {
+		msg, ok := deadLetters.PollMessage()
+		if !ok {
+			t.Fatalf("Message %d should be dead-lettered", e.payload)
+		}
+		if msg.Payload != e.payload || msg.Topic != "orders.dlq" {
+			t.Errorf("Invalid Message: %+v", msg)
+		}
+		if msg.Header(HeaderDeadLetterReason) != e.reason || msg.Header(HeaderDeliveryAttempts) != e.attempts {
+			t.Errorf("Invalid Headers: Expected: %s/%s Obtained: %v", e.reason, e.attempts, msg.Headers)
+		}
+		if msg.Header(HeaderOriginalTopic) != "orders" {
+			t.Errorf("Invalid Original Topic: %v", msg.Headers)
+		}
+	}
+
+	if _, _, available := subscriber.PollTimeout(30 * time.Millisecond); available {
+		t.Error("Dead-lettered messages should not be redelivered")
+	}
+}
+
+func TestAckSubscriptionDeadLetterAfterVisibilityTimeout(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	deadLetters := broker.SubscribeAck(ExactMatcher("dlq"))
+	subscriber := broker.SubscribeAck(
+		ExactMatcher("test"),
+		WithMaxDeliveries(1),
+		WithDeadLetterTopic("dlq"),
+		WithVisibilityTimeout(10*time.Millisecond),
+	)
+
+	broker.PublishMessage(Message[int]{ID: "message-1", Topic: "test", Payload: 1})
+	subscriber.Poll()
+
+	delivery, _, available := deadLetters.PollTimeout(time.Second)
+	if !available {
+		t.Fatal("Message should be dead-lettered once its visibility timeout expired")
+	}
+	if delivery.ID != "message-1" || delivery.Header(HeaderDeadLetterReason) != DeadLetterMaxDeliveries {
+		t.Errorf("Invalid Delivery: %+v", delivery)
+	}
+	delivery.Ack()
+}
+
+func TestAckSubscriptionDeadLetterExpired(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	deadLetters := broker.Subscribe(ExactMatcher("dlq"))
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithDeadLetterTopic("dlq"))
+
+	broker.PublishMessage(Message[int]{Topic: "test", TTL: 10 * time.Millisecond, Payload: 1})
+	broker.PublishMessage(Message[int]{Topic: "test", TTL: 20 * time.Millisecond, Payload: 2})
+
+	delivery, _, _ := subscriber.TryPoll()
+	time.Sleep(30 * time.Millisecond)
+
+	// A requeued message expiring meanwhile is dead-lettered as well
+	delivery.Nack(true)
+	if _, _, available := subscriber.TryPoll(); available {
+		t.Error("Expired messages should not be delivered")
+	}
+
+	// The message held by the subscription is dead-lettered once it expires, the requeued one when it is rejected
+	payloads := 0
+	for i := 0; i < 2; i++ {
+		msg, ok := deadLetters.PollMessage()
+		if !ok {
+			t.Fatal("Both messages should be dead-lettered")
+		}
+		if msg.Header(HeaderDeadLetterReason) != DeadLetterExpired || msg.TTL != 0 {
+			t.Errorf("Invalid Message: %+v", msg)
+		}
+		payloads += msg.Payload
+	}
+	if payloads != 3 {
+		t.Errorf("Invalid Payloads: Expected: 1 and 2 Obtained: a sum of %d", payloads)
+	}
+}
+
+func TestAckSubscriptionDeadLetterExpiredWithoutPoll(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	deadLetters := broker.Subscribe(ExactMatcher("dlq"))
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithDeadLetterTopic("dlq"))
+
+	expired := make(chan int, 1)
+	subscriber.OnExpiry(func(msg Message[int]) {
+		expired <- msg.Payload
+	})
+
+	broker.PublishMessage(Message[int]{Topic: "test", TTL: 10 * time.Millisecond, Payload: 1})
+
+	if val := <-expired; val != 1 {
+		t.Errorf("Invalid Expired Value: Expected: 1 Obtained: %d", val)
+	}
+	msg, _, available := deadLetters.PollTimeout(time.Second)
+	if !available || msg != 1 {
+		t.Fatalf("Expired message should be dead-lettered without a poll, Obtained: %v", msg)
+	}
+	if length := subscriber.Len(); length != 0 {
+		t.Errorf("Invalid Len: Expected: 0 Obtained: %d", length)
+	}
+}
+
+func TestAckSubscriptionRequeueKeepsQueueTTL(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithQueueOptions(queue.WithTTL(50*time.Millisecond)))
+	expired := make(chan int, 1)
+	subscriber.OnExpiry(func(msg Message[int]) {
+		expired <- msg.Payload
+	})
+
+	broker.Publish("test", 1)
+
+	// Every redelivery counts from the first store, so the message expires however often it is requeued
+	timeout := time.After(time.Second)
+	for deliveries := 0; ; {
+		select {
+		case val := <-expired:
+			if val != 1 || deliveries == 0 {
+				t.Errorf("Invalid Expiry: Expected: 1 after a delivery, Obtained: %d after %d", val, deliveries)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("The message should expire once its TTL elapsed, it was delivered %d times", deliveries)
+		default:
+		}
+
+		if delivery, _, available := subscriber.PollTimeout(5 * time.Millisecond); available {
+			deliveries++
+			time.Sleep(5 * time.Millisecond)
+			delivery.Nack(true)
+		}
+	}
+}
+
+func TestAckSubscriptionRequeueMessageTTL(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"))
+
+	// The TTL counts from the time the message is stored, not from its Timestamp
+	broker.PublishMessage(Message[int]{Topic: "test", Timestamp: time.Now().Add(-time.Hour), TTL: time.Minute, Payload: 1})
+
+	delivery, _, available := subscriber.TryPoll()
+	if !available {
+		t.Fatal("The message should be delivered")
+	}
+	delivery.Nack(true)
+
+	if delivery, _, available = subscriber.TryPoll(); !available || delivery.Payload != 1 || delivery.Attempt != 2 {
+		t.Fatalf("The requeued message should be delivered again, Obtained: %+v", delivery)
+	}
+}
+
+func TestAckSubscriptionDrain(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"))
+	broker.Publish("test", 1)
+	delivery, _ := subscriber.Poll()
+
+	drained := make(chan error)
+	go func() {
+		drained <- broker.Drain(context.Background())
+	}()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain should wait for the delivery to be settled, Obtained: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// A delivery rejected while draining is requeued
+	if err := delivery.Nack(true); err != nil {
+		t.Errorf("Nack should succeed, Obtained: %v", err)
+	}
+	redelivered, ok := subscriber.Poll()
+	if !ok || redelivered.Payload != 1 || redelivered.Attempt != 2 {
+		t.Fatalf("Invalid Delivery: %+v", redelivered)
+	}
+	redelivered.Ack()
+
+	if err := <-drained; err != nil {
+		t.Errorf("Invalid Error: Expected: nil Obtained: %v", err)
+	}
+	if !subscriber.IsClosed() {
+		t.Error("Drain should close the subscription once settled")
+	}
+}
+
+func TestAckSubscriptionShutdown(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.SubscribeAck(ExactMatcher("test"))
+	broker.Publish("test", 1)
+	broker.Publish("test", 2)
+	delivery, _ := subscriber.Poll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	leftovers, err := broker.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", context.DeadlineExceeded, err)
+	}
+	if len(leftovers) != 1 || len(leftovers[0].Messages) != 2 {
+		t.Fatalf("Invalid Leftovers: Expected: 2 messages Obtained: %+v", leftovers)
+	}
+	if first, second := leftovers[0].Messages[0], leftovers[0].Messages[1]; first.Payload != 1 || second.Payload != 2 {
+		t.Errorf("Invalid Leftover Messages: Expected: 1 then 2 Obtained: %+v", leftovers[0].Messages)
+	}
+
+	if err := delivery.Ack(); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrUnknownDelivery, err)
+	}
+	if inFlight := subscriber.InFlight(); inFlight != 0 {
+		t.Errorf("Invalid InFlight: Expected: 0 Obtained: %d", inFlight)
+	}
+}
+
+func TestAckSubscriptionDeadLetterWhileStopping(t *testing.T) {
+	broker := NewBroker[int]()
+
+	broker.Subscribe(ExactMatcher("dlq"))
+	subscriber := broker.SubscribeAck(ExactMatcher("test"), WithDeadLetterTopic("dlq"))
+	broker.Publish("test", 1)
+	delivery, _ := subscriber.Poll()
+
+	shutdown := make(chan []Leftover[int])
+	go func() {
+		leftovers, _ := broker.Shutdown(context.Background())
+		shutdown <- leftovers
+	}()
+	for broker.State() == Running {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The dead-letter topic is stopped as well, the message is handed back by Shutdown
+	if err := delivery.Nack(false); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+
+	leftovers := <-shutdown
+	if len(leftovers) != 1 || len(leftovers[0].Messages) != 0 || len(leftovers[0].DeadLetters) != 1 {
+		t.Fatalf("Invalid Leftovers: Expected: 1 dead letter Obtained: %+v", leftovers)
+	}
+	msg := leftovers[0].DeadLetters[0]
+	if msg.Payload != 1 || msg.Topic != "dlq" || msg.Header(HeaderDeadLetterReason) != DeadLetterRejected {
+		t.Errorf("Invalid Dead Letter: %+v", msg)
+	}
+}
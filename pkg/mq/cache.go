@@ -0,0 +1,237 @@
+package mq
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMatchCacheLimit is the number of topics whose matches are cached by default
+const DefaultMatchCacheLimit = 4096
+
+const (
+	// maxCacheShards bounds the number of shards of the match cache, a power of 2
+	maxCacheShards = 16
+
+	// minCacheShardSize is the smallest limit of a shard, smaller caches have fewer shards
+	minCacheShardSize = 64
+)
+
+// MatchCacheStats is a snapshot of the counters of the match cache of a broker
+type MatchCacheStats struct {
+	// Size is the number of cached topics and Limit the maximum, 0 when unbounded
+	Size  int
+	Limit int
+
+	// Disabled reports whether the broker was created WithoutMatchCache
+	Disabled bool
+
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// cached is an entry of the match cache
+type cached[T any] struct {
+	topic   string
+	matched []*queueMatcher[T]
+
+	// used is set by every hit and cleared as the eviction sweeps past the entry
+	used atomic.Bool
+}
+
+// matchCache maps the topics to the subscriptions matching them
+// The topics are spread over shards, so that publishers of different topics do not contend,
+// and a hit only takes the read lock of its shard. Once a shard is full, caching a topic evicts
+// a topic not used since the eviction last swept past it, an approximation of the least recently used one.
+// The cached slices are never modified, they are replaced when a subscription is added or removed.
+// The entries belong to the table of the generation, a publisher holding another table neither reads nor caches them.
+type matchCache[T any] struct {
+	limit    int
+	disabled bool
+	shards   []cacheShard[T]
+}
+
+// cacheShard holds the topics of the match cache hashed to it
+type cacheShard[T any] struct {
+	mu         sync.RWMutex
+	limit      int
+	generation uint64
+	entries    map[string]*cached[T]
+
+	// ring holds the entries in the order the eviction sweeps them, starting at hand
+	ring []*cached[T]
+	hand int
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	// Keep the shards on distinct cache lines
+	_ [64]byte
+}
+
+func newMatchCache[T any](limit int, disabled bool) *matchCache[T] {
+	if limit < 0 {
+		limit = 0
+	}
+
+	n := maxCacheShards
+	for limit > 0 && n > 1 && limit/n < minCacheShardSize {
+		n /= 2
+	}
+
+	c := &matchCache[T]{
+		limit:    limit,
+		disabled: disabled,
+		shards:   make([]cacheShard[T], n),
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.entries = make(map[string]*cached[T])
+		if limit > 0 {
+			// The first shards take the remainder, so that the limits add up to the limit of the cache
+			s.limit = limit / n
+			if i < limit%n {
+				s.limit++
+			}
+		}
+	}
+
+	return c
+}
+
+// shard returns the shard holding the topic
+func (c *matchCache[T]) shard(topic string) *cacheShard[T] {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(topic); i++ {
+		h ^= uint32(topic[i])
+		h *= 16777619
+	}
+	return &c.shards[h&uint32(len(c.shards)-1)]
+}
+
+// get returns the cached subscriptions of the table of the generation matching the topic
+func (c *matchCache[T]) get(topic string, generation uint64) ([]*queueMatcher[T], bool) {
+	s := c.shard(topic)
+
+	s.mu.RLock()
+	e, ok := s.entries[topic]
+	if !ok || generation != s.generation {
+		s.mu.RUnlock()
+		s.misses.Add(1)
+		return nil, false
+	}
+
+	matched := e.matched
+	// Only the first hit since the last sweep writes to the entry
+	if !e.used.Load() {
+		e.used.Store(true)
+	}
+	s.mu.RUnlock()
+
+	s.hits.Add(1)
+	return matched, true
+}
+
+// put caches the subscriptions of the table of the generation matching the topic,
+// evicting a topic of its shard if the shard is full
+func (c *matchCache[T]) put(topic string, matched []*queueMatcher[T], generation uint64) {
+	if c.disabled {
+		return
+	}
+
+	s := c.shard(topic)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if generation != s.generation {
+		return
+	}
+
+	if e, ok := s.entries[topic]; ok {
+		e.matched = matched
+		return
+	}
+
+	e := &cached[T]{topic: topic, matched: matched}
+	s.entries[topic] = e
+
+	if s.limit <= 0 || len(s.ring) < s.limit {
+		s.ring = append(s.ring, e)
+		return
+	}
+
+	// Give a second chance to the entries used since the last sweep, every entry is used at most once per round
+	for {
+		victim := s.ring[s.hand]
+		if victim.used.Load() {
+			victim.used.Store(false)
+			s.hand = (s.hand + 1) % len(s.ring)
+			continue
+		}
+
+		delete(s.entries, victim.topic)
+		s.evictions.Add(1)
+		s.ring[s.hand] = e
+		s.hand = (s.hand + 1) % len(s.ring)
+		return
+	}
+}
+
+// update moves the cache to the table of the generation, replacing the subscriptions of every cached topic
+// with the ones returned by fn, unless it returns false
+func (c *matchCache[T]) update(generation uint64, fn func(topic string, matched []*queueMatcher[T]) ([]*queueMatcher[T], bool)) {
+	for i := range c.shards {
+		s := &c.shards[i]
+
+		s.mu.Lock()
+		s.generation = generation
+		for _, e := range s.ring {
+			if matched, ok := fn(e.topic, e.matched); ok {
+				e.matched = matched
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// reset empties the cache and moves it to the table of the generation, keeping its counters
+func (c *matchCache[T]) reset(generation uint64) {
+	for i := range c.shards {
+		s := &c.shards[i]
+
+		s.mu.Lock()
+		s.generation = generation
+		s.entries = make(map[string]*cached[T])
+		s.ring = nil
+		s.hand = 0
+		s.mu.Unlock()
+	}
+}
+
+func (c *matchCache[T]) stats() MatchCacheStats {
+	stats := MatchCacheStats{
+		Limit:    c.limit,
+		Disabled: c.disabled,
+	}
+
+	for i := range c.shards {
+		s := &c.shards[i]
+
+		s.mu.RLock()
+		stats.Size += len(s.ring)
+		s.mu.RUnlock()
+
+		stats.Hits += s.hits.Load()
+		stats.Misses += s.misses.Load()
+		stats.Evictions += s.evictions.Load()
+	}
+
+	return stats
+}
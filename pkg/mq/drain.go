@@ -0,0 +1,99 @@
+package mq
+
+import "context"
+
+// Leftover holds the messages a subscription still held when the broker was shut down
+type Leftover[T any] struct {
+	ID      uint64
+	Matcher Matcher
+
+	// Group is the name of the consumer group, empty for a plain subscription
+	Group string
+
+	Messages []Message[T]
+
+	// DeadLetters are the messages an acknowledged subscription dead-lettered while the broker was stopping,
+	// which could not reach the dead-letter topic. They carry the dead-letter topic and headers.
+	DeadLetters []Message[T]
+}
+
+func (b *broker[T]) Drain(ctx context.Context) error {
+	previous, queueMatchers := b.stop(Draining)
+	if previous == Closed {
+		return ErrBrokerClosed
+	}
+
+	for _, qm := range queueMatchers {
+		if err := qm.drain(ctx); err != nil {
+			return err
+		}
+	}
+	b.finish()
+
+	return nil
+}
+
+func (b *broker[T]) Shutdown(ctx context.Context) ([]Leftover[T], error) {
+	previous, queueMatchers := b.stop(Draining)
+	if previous == Closed {
+		return nil, ErrBrokerClosed
+	}
+
+	var err error
+	held := make([][]envelope[T], len(queueMatchers))
+	for i, qm := range queueMatchers {
+		// The unsettled deliveries are older than the messages held
+		if qm.ack != nil {
+			if qerr := qm.ack.waitSettled(ctx); qerr != nil {
+				held[i] = qm.ack.abandon()
+			}
+		}
+
+		// Once the context is done, the remaining subscriptions hand their messages back at once
+		envs, qerr := qm.queue.Shutdown(ctx)
+		if qerr != nil {
+			err = qerr
+		}
+		held[i] = append(held[i], envs...)
+	}
+
+	// The deliveries of every subscription are settled or abandoned, none can be dead-lettered anymore
+	var leftovers []Leftover[T]
+	for i, qm := range queueMatchers {
+		var deadLetters []Message[T]
+		if qm.ack != nil {
+			deadLetters = qm.ack.takeDeadLetters()
+		}
+		if len(held[i]) == 0 && len(deadLetters) == 0 {
+			continue
+		}
+
+		leftover := Leftover[T]{
+			ID:          qm.id,
+			Matcher:     qm.matcher,
+			Group:       qm.group,
+			Messages:    make([]Message[T], len(held[i])),
+			DeadLetters: deadLetters,
+		}
+		for j, env := range held[i] {
+			leftover.Messages[j] = env.Message
+		}
+		leftovers = append(leftovers, leftover)
+	}
+	b.finish()
+
+	return leftovers, err
+}
+
+// drain waits until the subscription was polled and, for an acknowledged one, until its deliveries are settled
+// An acknowledged subscription is only closed once settled, so that the rejected deliveries can be requeued
+func (qm *queueMatcher[T]) drain(ctx context.Context) error {
+	if qm.ack != nil {
+		if err := qm.ack.waitSettled(ctx); err != nil {
+			qm.queue.Close(-1)
+			return err
+		}
+	}
+
+	return qm.queue.Drain(ctx)
+}
@@ -0,0 +1,174 @@
+package mq
+
+import "strings"
+
+// index finds the subscriptions matching a topic without calling every matcher
+// ExactMatchers are looked up by topic and TopicMatchers through a trie of their levels,
+// any other Matcher, e.g. a regexp, is scanned.
+// An index is never modified, adding or removing a subscription returns a copy sharing everything
+// but the path to the subscription, so that it costs O(log n) instead of a copy of the whole index.
+type index struct {
+	exact  pmap[string, idSet]
+	topics *topicNode
+	others pmap[uint64, Matcher]
+}
+
+// idSet is a set of subscription ids
+type idSet = pmap[uint64, struct{}]
+
+// topicNode is a level of the TopicMatcher patterns, wildcards included, nil being an empty level
+type topicNode struct {
+	children pmap[string, *topicNode]
+
+	// ids are the subscriptions whose pattern ends at this level
+	ids idSet
+}
+
+func newIndex() *index {
+	return &index{}
+}
+
+// with returns a copy of the index with the subscription with the id indexed under its matcher
+func (x *index) with(id uint64, matcher Matcher) *index {
+	c := *x
+	switch m := matcher.(type) {
+	case ExactMatcher:
+		ids, _ := c.exact.get(string(m))
+		c.exact = c.exact.set(string(m), ids.set(id, struct{}{}))
+	case TopicMatcher:
+		if m.pattern == "" {
+			// The zero TopicMatcher matches nothing
+			return x
+		}
+		c.topics = c.topics.with(strings.Split(m.pattern, TopicSeparator), id)
+	default:
+		c.others = c.others.set(id, matcher)
+	}
+
+	return &c
+}
+
+// without returns a copy of the index without the subscription with the id
+func (x *index) without(id uint64, matcher Matcher) *index {
+	c := *x
+	switch m := matcher.(type) {
+	case ExactMatcher:
+		ids, ok := c.exact.get(string(m))
+		if !ok {
+			return x
+		}
+		if ids = ids.delete(id); ids.len() == 0 {
+			c.exact = c.exact.delete(string(m))
+		} else {
+			c.exact = c.exact.set(string(m), ids)
+		}
+	case TopicMatcher:
+		if m.pattern == "" {
+			return x
+		}
+		c.topics = c.topics.without(strings.Split(m.pattern, TopicSeparator), id)
+	default:
+		c.others = c.others.delete(id)
+	}
+
+	return &c
+}
+
+// with returns a copy of the node with the id added to the node reached through the levels
+// Only the nodes on the way are copied, the other children are shared.
+func (n *topicNode) with(levels []string, id uint64) *topicNode {
+	c := &topicNode{}
+	if n != nil {
+		*c = *n
+	}
+
+	if len(levels) == 0 {
+		c.ids = c.ids.set(id, struct{}{})
+		return c
+	}
+
+	child, _ := c.children.get(levels[0])
+	c.children = c.children.set(levels[0], child.with(levels[1:], id))
+	return c
+}
+
+// without returns a copy of the node with the id dropped from the node reached through the levels,
+// pruning the nodes left empty, nil if the node itself is left empty
+func (n *topicNode) without(levels []string, id uint64) *topicNode {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	if len(levels) == 0 {
+		c.ids = c.ids.delete(id)
+	} else if child, ok := c.children.get(levels[0]); ok {
+		if child = child.without(levels[1:], id); child == nil {
+			c.children = c.children.delete(levels[0])
+		} else {
+			c.children = c.children.set(levels[0], child)
+		}
+	}
+
+	if c.ids.len() == 0 && c.children.len() == 0 {
+		return nil
+	}
+	return &c
+}
+
+// match returns the ids of the subscriptions matching the topic
+func (x *index) match(topic string) map[uint64]bool {
+	matched := make(map[uint64]bool)
+	add := func(id uint64, _ struct{}) {
+		matched[id] = true
+	}
+
+	if ids, ok := x.exact.get(topic); ok {
+		ids.each(add)
+	}
+	x.topics.match(topic, add)
+	x.others.each(func(id uint64, matcher Matcher) {
+		if matcher.MatchString(topic) {
+			matched[id] = true
+		}
+	})
+
+	return matched
+}
+
+// match passes the ids of the patterns below the node matching the remaining levels of the topic to add
+func (n *topicNode) match(topic string, add func(uint64, struct{})) {
+	if n == nil {
+		return
+	}
+
+	// Both multi-level wildcards match the remaining levels, there is at least one
+	for _, wildcard := range []string{MultiLevelWildcard, TailWildcard} {
+		if child, ok := n.children.get(wildcard); ok {
+			child.ids.each(add)
+		}
+	}
+
+	level, rest, more := strings.Cut(topic, TopicSeparator)
+	for _, key := range []string{level, SingleLevelWildcard} {
+		child, ok := n.children.get(key)
+		if !ok {
+			continue
+		}
+
+		if !more {
+			child.ids.each(add)
+			// A trailing # also matches the absence of levels
+			if all, ok := child.children.get(MultiLevelWildcard); ok {
+				all.ids.each(add)
+			}
+		} else {
+			child.match(rest, add)
+		}
+
+		if level == SingleLevelWildcard {
+			// The literal level * is the wildcard child itself
+			break
+		}
+	}
+}
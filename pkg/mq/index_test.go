@@ -0,0 +1,142 @@
+package mq
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestIndexMatchesLikeMatchers(t *testing.T) {
+	matchers := []Matcher{
+		ExactMatcher("orders"),
+		ExactMatcher("orders.*"),
+		MustTopicMatcher("orders"),
+		MustTopicMatcher("orders.*"),
+		MustTopicMatcher("orders.*.created"),
+		MustTopicMatcher("*.eu.*"),
+		MustTopicMatcher("orders.#"),
+		MustTopicMatcher("orders.>"),
+		MustTopicMatcher("#"),
+		MustTopicMatcher(">"),
+		MustTopicMatcher("orders.eu.#"),
+		regexp.MustCompile(`^orders\.\w+$`),
+	}
+	topics := []string{"", "orders", "orders.", "orders.*", "orders.eu", "orders.eu.created", "orders.eu.created.v2", "users.eu.created", ".eu.", "orders..created"}
+
+	x := newIndex()
+	for i, matcher := range matchers {
+		x = x.with(uint64(i), matcher)
+	}
+
+	for _, topic := range topics {
+		matched := x.match(topic)
+		for i, matcher := range matchers {
+			if expected := matcher.MatchString(topic); matched[uint64(i)] != expected {
+				t.Errorf("Invalid Match of %q with %v: Expected: %v, Obtained: %v", topic, matcher, expected, matched[uint64(i)])
+			}
+		}
+	}
+
+	for i, matcher := range matchers {
+		x = x.without(uint64(i), matcher)
+	}
+	if x.exact.len() != 0 || x.topics != nil || x.others.len() != 0 {
+		t.Errorf("The index should be empty once every subscription is removed")
+	}
+}
+
+func TestIndexSharedPattern(t *testing.T) {
+	x := newIndex().
+		with(1, MustTopicMatcher("orders.*")).
+		with(2, MustTopicMatcher("orders.*")).
+		with(3, MustTopicMatcher("orders.*.created"))
+
+	x = x.without(1, MustTopicMatcher("orders.*"))
+	if matched := x.match("orders.eu"); len(matched) != 1 || !matched[2] {
+		t.Errorf("Invalid Matches: Expected: map[2:true], Obtained: %v", matched)
+	}
+
+	x = x.without(3, MustTopicMatcher("orders.*.created"))
+	if matched := x.match("orders.eu.created"); len(matched) != 0 {
+		t.Errorf("Invalid Matches: Expected: map[], Obtained: %v", matched)
+	}
+}
+
+func TestIndexCopies(t *testing.T) {
+	before := newIndex().
+		with(1, ExactMatcher("orders")).
+		with(2, MustTopicMatcher("orders.*"))
+
+	after := before.
+		with(3, ExactMatcher("orders")).
+		with(4, MustTopicMatcher("orders.*")).
+		without(1, ExactMatcher("orders")).
+		without(2, MustTopicMatcher("orders.*"))
+
+	if matched := before.match("orders"); len(matched) != 1 || !matched[1] {
+		t.Errorf("Invalid Matches: Expected: map[1:true], Obtained: %v", matched)
+	}
+	if matched := before.match("orders.eu"); len(matched) != 1 || !matched[2] {
+		t.Errorf("Invalid Matches: Expected: map[2:true], Obtained: %v", matched)
+	}
+	if matched := after.match("orders"); len(matched) != 1 || !matched[3] {
+		t.Errorf("Invalid Matches: Expected: map[3:true], Obtained: %v", matched)
+	}
+	if matched := after.match("orders.eu"); len(matched) != 1 || !matched[4] {
+		t.Errorf("Invalid Matches: Expected: map[4:true], Obtained: %v", matched)
+	}
+}
+
+func TestPmap(t *testing.T) {
+	var m pmap[uint64, int]
+	versions := []pmap[uint64, int]{m}
+	for i := uint64(0); i < 5000; i++ {
+		m = m.set(i, int(i))
+		if i%1000 == 0 {
+			versions = append(versions, m)
+		}
+	}
+	m = m.set(7, -7)
+
+	if m.len() != 5000 {
+		t.Errorf("Invalid Length: Expected: 5000, Obtained: %d", m.len())
+	}
+	if value, ok := m.get(7); !ok || value != -7 {
+		t.Errorf("Invalid Value: Expected: -7, Obtained: %d", value)
+	}
+
+	// The earlier versions are left untouched
+	for v, version := range versions {
+		if expected := v*1000 - 999; v > 0 && version.len() != expected {
+			t.Errorf("Invalid Length of Version %d: Expected: %d, Obtained: %d", v, expected, version.len())
+		}
+		if _, ok := version.get(4999); ok {
+			t.Errorf("Version %d should not hold 4999", v)
+		}
+	}
+
+	for i := uint64(0); i < 5000; i += 2 {
+		m = m.delete(i)
+	}
+	m = m.delete(5000)
+
+	count := 0
+	m.each(func(key uint64, value int) {
+		count++
+		if key%2 == 0 {
+			t.Errorf("Deleted key %d is still held", key)
+		}
+	})
+	if count != 2500 || m.len() != 2500 {
+		t.Errorf("Invalid Length: Expected: 2500, Obtained: %d and %d", count, m.len())
+	}
+	if value, ok := m.get(4999); !ok || value != 4999 {
+		t.Errorf("Invalid Value: Expected: 4999, Obtained: %d", value)
+	}
+
+	for i := uint64(1); i < 5000; i += 2 {
+		m = m.delete(i)
+	}
+	if m.len() != 0 || m.root != nil {
+		t.Errorf("The map should be empty once every key is deleted")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build go1.23
+
+package mq
+
+import (
+	"iter"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+// All returns an iterator polling the payloads of p until it is closed and drained, see queue.All
+func All[T any](p Poller[T]) iter.Seq[T] {
+	return queue.All[T](p)
+}
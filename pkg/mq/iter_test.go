@@ -0,0 +1,23 @@
+//go:build go1.23
+
+package mq
+
+import "testing"
+
+func TestAll(t *testing.T) {
+	broker := NewBroker[int]()
+	sub := broker.Subscribe(ExactMatcher("test"))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("test", i)
+	}
+	broker.Close(-1)
+
+	var values []int
+	for v := range All(sub) {
+		values = append(values, v)
+	}
+	if len(values) != 3 || values[2] != 2 {
+		t.Errorf("Invalid Values: Expected: [0 1 2] Obtained: %v", values)
+	}
+}
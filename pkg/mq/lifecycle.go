@@ -0,0 +1,72 @@
+package mq
+
+// State is a stage of the lifecycle of a broker
+type State int32
+
+const (
+	// Running brokers accept subscriptions and publishes
+	Running State = iota
+
+	// Draining brokers wait for their subscriptions to be polled, they reject new subscriptions and publishes
+	Draining
+
+	// Closed brokers reject new subscriptions and publishes
+	Closed
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Running:
+		return "running"
+	case Draining:
+		return "draining"
+	case Closed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+func (b *broker[T]) State() State {
+	return State(b.state.Load())
+}
+
+func (b *broker[T]) Done() <-chan struct{} {
+	return b.done
+}
+
+// stop moves the broker to the next state and returns the state it was in along with the subscriptions to stop
+// Leaving the running state removes every subscription, a draining broker returns the subscriptions being drained
+// and a closed broker returns none.
+func (b *broker[T]) stop(next State) (State, []*queueMatcher[T]) {
+	b.Lock()
+	defer b.Unlock()
+
+	previous := b.State()
+	switch previous {
+	case Closed:
+		return previous, nil
+	case Running:
+		current := b.table.Load()
+		t := newTable[T](current.generation + 1)
+		b.table.Store(t)
+		b.matchCache.reset(t.generation)
+		b.stopped = current.queueMatchers
+	}
+
+	b.state.Store(int32(next))
+	return previous, b.stopped
+}
+
+// finish closes the broker once its subscriptions are stopped, signalling Done
+func (b *broker[T]) finish() {
+	b.Lock()
+	b.state.Store(int32(Closed))
+	b.stopped = nil
+	b.Unlock()
+
+	b.finished.Do(func() {
+		close(b.done)
+	})
+}
@@ -0,0 +1,44 @@
+package mq
+
+import "time"
+
+// Message is the envelope in which data travels from a publisher to the subscribers
+type Message[T any] struct {
+	// ID identifies the message, the broker assigns one when it is empty
+	ID string
+
+	// Topic is the topic the message was published to
+	Topic string
+
+	// Timestamp is the time the message was published at, the broker sets it when it is zero
+	Timestamp time.Time
+
+	// Headers carry metadata such as routing or tracing information
+	// Every subscription receives a copy, changing it affects neither the publisher nor the other subscriptions
+	Headers map[string]string
+
+	// Priority orders the message in the subscriptions created with queue.WithPriority, higher is more urgent
+	Priority int
+
+	// TTL discards the message if it is not polled within the duration, overriding the TTL of the subscriptions
+	// A TTL of 0 means the TTL of each subscription applies
+	TTL time.Duration
+
+	// Payload is the published data
+	Payload T
+}
+
+// Header returns the value of the header with the key, or an empty string if it is not set
+func (m Message[T]) Header(key string) string {
+	return m.Headers[key]
+}
+
+// envelope is a message held by the queue of a subscription along with its delivery count
+type envelope[T any] struct {
+	Message[T]
+	deliveries int
+
+	// deadline is the time the message expires in an acknowledged subscription, zero if it never expires
+	// The queue sets it once the message is first stored, a redelivery keeps it. It is nil for the other subscriptions.
+	deadline *time.Time
+}
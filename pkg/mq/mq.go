@@ -1,7 +1,12 @@
 package mq
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Dev-Destructor/go-queue/pkg/queue"
@@ -17,44 +22,183 @@ type Matcher interface {
 	MatchString(string) bool
 }
 
-type queueMatcher struct {
-	queue   queue.Queue
+// equalMatchers reports whether the matchers are equal, without panicking on the values which are not comparable
+func equalMatchers(a, b Matcher) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || !va.Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+type queueMatcher[T any] struct {
+	id      uint64
+	queue   queue.Queue[envelope[T]]
 	matcher Matcher
+
+	// ack is the acknowledged subscription reading the queue, nil for a plain subscription or a group
+	ack *ackSubscription[T]
+
+	// group is the name of the consumer group sharing the queue, empty for a plain subscription
+	group   string
+	members int
 }
 
-type broker struct {
-	queueMatchers []queueMatcher
+type broker[T any] struct {
+	// table is the current snapshot of the subscriptions, replaced under the lock
+	table  atomic.Pointer[table[T]]
+	nextID uint64
+
+	// sequence numbers the messages published without an ID
+	sequence atomic.Uint64
+
+	// state is the State of the broker, stopped holds the subscriptions removed by Drain or Shutdown
+	// until the broker is closed, and done is closed once the broker is
+	state    atomic.Int32
+	stopped  []*queueMatcher[T]
+	done     chan struct{}
+	finished sync.Once
 
 	// ~11.5% faster operation speed while caching the matchers
-	matchCache map[string]map[Matcher]bool
-	sync.RWMutex
+	// The cache maps a topic to the subscriptions matching it, in the order they were created
+	matchCache *matchCache[T]
+	options    options
+
+	// The lock serializes the changes of the subscriptions, publishers never take it
+	sync.Mutex
 }
 
-// Poller is wrapper for Poll function
-type Poller interface {
+// Poller is wrapper for Poll function over values of type T
+type Poller[T any] interface {
 
 	// Poller reads the data from queue and returns the value.
 	// It will wait till there is consumable data.
 	// If the resource is closed, then Poller will return,
-	Poll() (interface{}, bool)
+	Poll() (T, bool)
+
+	// PollContext works like Poll but returns ctx.Err() once the context is done
+	// and queue.ErrClosed if the resource is closed.
+	PollContext(ctx context.Context) (T, error)
+
+	// TryPoll reads the data from queue without blocking.
+	// available reports whether a value was read and ok is false once the resource is closed.
+	TryPoll() (value T, ok bool, available bool)
+
+	// PollTimeout works like TryPoll but waits at most d for a value to become available.
+	PollTimeout(d time.Duration) (value T, ok bool, available bool)
+
+	// PollBatch reads up to max values at once, waiting at most wait for the first one.
+	PollBatch(max int, wait time.Duration) []T
+
+	// Chan returns a channel receiving the polled values, closed once the resource is closed and drained.
+	// Every call returns the same channel, see queue.Queue.C.
+	Chan() <-chan T
+
+	// Len returns the number of values waiting to be polled.
+	Len() int
+
+	// IsClosed reports whether the resource is closed.
+	IsClosed() bool
+
+	// Stats returns a snapshot of the counters of the resource.
+	Stats() queue.Stats
 }
 
-// Broker is the broker for interaction
-type Broker interface {
+// Broker is the broker for interaction, carrying payloads of type T
+type Broker[T any] interface {
 
 	// Publish publishes data to a specific topic.
-	Publish(topic string, data interface{})
+	// Once the broker is draining or closed, the data is discarded, PublishE reports it with ErrBrokerClosed.
+	Publish(topic string, data T)
+
+	// PublishAfter publishes data to a specific topic once the delay has elapsed.
+	// The data is held by the subscriptions matching the topic at the time of the call.
+	// Once the broker is draining or closed, the data is discarded.
+	PublishAfter(topic string, data T, delay time.Duration)
+
+	// PublishE publishes data to a specific topic and reports how many subscriptions matched, accepted and dropped it.
+	// It returns ErrBrokerClosed once the broker is draining or closed, and ErrNoSubscribers for a Mandatory publish no subscription matches.
+	PublishE(topic string, data T, opts ...PublishOption) (PublishResult, error)
+
+	// PublishBatch publishes the data to a specific topic, handing it to each subscription at once.
+	// Every element travels in its own message.
+	// Once the broker is draining or closed, the data is discarded.
+	PublishBatch(topic string, data []T)
+
+	// PublishMessage publishes the message to its topic.
+	// The broker assigns an ID and a Timestamp to the message if they are not set.
+	// Once the broker is draining or closed, the message is discarded, PublishMessageE reports it with ErrBrokerClosed.
+	PublishMessage(msg Message[T])
+
+	// PublishMessageE publishes the message to its topic like PublishMessage, and reports the outcome like PublishE.
+	PublishMessageE(msg Message[T], opts ...PublishOption) (PublishResult, error)
+
+	// PublishContext publishes data to a specific topic.
+	// It returns ErrBrokerClosed once the broker is draining or closed.
+	// It returns ctx.Err() if the context is done before every matched subscriber accepted the data,
+	// in which case the data may have been delivered to some of the subscribers.
+	PublishContext(ctx context.Context, topic string, data T) error
+
+	// Subscribe creates a Subscription which polls data from matched topics.
+	// Once the broker is draining or closed, the Subscription is created closed, SubscribeE reports it with ErrBrokerClosed.
+	// The options configure the queue backing the subscription, e.g. its capacity and overflow policy.
+	// With queue.WithPriority, messages of higher Message.Priority are polled first.
+	Subscribe(topic Matcher, opts ...queue.Option) Subscription[T]
+
+	// SubscribeE works like Subscribe but returns ErrBrokerClosed instead of a closed Subscription
+	// once the broker is draining or closed.
+	SubscribeE(topic Matcher, opts ...queue.Option) (Subscription[T], error)
 
-	// Subscribe creates a Poller which polls data from matched topics.
-	Subscribe(topic Matcher) Poller
+	// SubscribeGroup creates a Subscription sharing its queue with the members of the group using an equal matcher.
+	// Each message is delivered to a single member of the group, while every group and plain subscription still receives it.
+	// The options are only used by the first member, which creates the queue of the group.
+	// A matcher whose value is not comparable equals no other, each such subscription has a queue of its own.
+	SubscribeGroup(group string, topic Matcher, opts ...queue.Option) Subscription[T]
 
-	// CloseTopic closes the topic and removes the topic from the broker.
+	// SubscribeGroupE works like SubscribeGroup but returns ErrBrokerClosed once the broker is draining or closed.
+	SubscribeGroupE(group string, topic Matcher, opts ...queue.Option) (Subscription[T], error)
+
+	// SubscribeAck creates an AckSubscription delivering the messages of matched topics at least once.
+	// Once the broker is draining or closed, the AckSubscription is created closed.
+	SubscribeAck(topic Matcher, opts ...AckOption) AckSubscription[T]
+
+	// SubscribeAckE works like SubscribeAck but returns ErrBrokerClosed once the broker is draining or closed.
+	SubscribeAckE(topic Matcher, opts ...AckOption) (AckSubscription[T], error)
+
+	// CloseTopic closes every subscription created with an equal matcher and removes them from the broker.
+	// A matcher whose value is not comparable equals no other, it closes nothing.
 	// If the timeOut is less than 0, then all the resources will be read-only.
 	CloseTopic(topic Matcher, timeOut time.Duration)
 
+	// Stats returns a snapshot of the counters of every subscription.
+	Stats() Stats
+
+	// Drain moves the broker to Draining and waits until every subscription was polled, see queue.Queue.Drain,
+	// and until every delivery of the acknowledged subscriptions is settled.
+	// The broker is then Closed. It returns ctx.Err() if the context is done first,
+	// the broker then keeps Draining with its subscriptions left read only, and ErrBrokerClosed if the broker is closed.
+	Drain(ctx context.Context) error
+
+	// Shutdown drains the broker like Drain, if the context is done first it closes the subscriptions
+	// and returns the messages they still held along with ctx.Err(), the unsettled deliveries included.
+	// The broker is Closed in both cases.
+	Shutdown(ctx context.Context) ([]Leftover[T], error)
+
 	// Close closes the broker and changes it to read only.
 	// If the timeOut is less than 0, then all the resources will be read-only.
+	// Close may be called several times and concurrently, a draining broker is closed leaving its subscriptions read only.
 	Close(timeOut time.Duration)
+
+	// State returns the stage of the lifecycle of the broker.
+	State() State
+
+	// Done returns a channel closed once the broker is closed and its subscriptions are stopped.
+	Done() <-chan struct{}
 }
 
 // MatchString returns true if the pattern matches the string
@@ -62,67 +206,324 @@ func (em ExactMatcher) MatchString(pattern string) bool {
 	return string(em) == pattern
 }
 
-func (b *broker) Publish(topic string, data interface{}) {
-	b.RLock()
-	matchers, ok := b.matchCache[topic]
-	b.RUnlock()
+func (b *broker[T]) Publish(topic string, data T) {
+	b.PublishMessage(Message[T]{Topic: topic, Payload: data})
+}
+
+func (b *broker[T]) PublishMessage(msg Message[T]) {
+	b.publish(msg)
+}
+
+func (b *broker[T]) PublishBatch(topic string, data []T) {
+	if len(data) == 0 {
+		return
+	}
+
+	envs := make([]envelope[T], len(data))
+	for i, d := range data {
+		envs[i] = envelope[T]{Message: b.stamp(Message[T]{Topic: topic, Payload: d})}
+	}
+
+	for _, qm := range b.match(topic) {
+		if qm.ack == nil {
+			qm.queue.PushBatch(envs)
+			continue
+		}
 
-	if !ok {
-		b.Lock()
-		matchers = make(map[Matcher]bool)
-		for _, q := range b.queueMatchers {
-			matchers[q.matcher] = q.matcher.MatchString(topic)
+		// Every message of an acknowledged subscription records its own deadline, which a batch can not carry
+		for _, env := range envs {
+			env, opts := qm.envelope(env.Message)
+			if err := qm.queue.Push(env, opts...); err != nil {
+				break
+			}
 		}
-		b.matchCache[topic] = matchers
-		b.Unlock()
 	}
+}
 
-	for _, q := range b.queueMatchers {
-		if matchers[q.matcher] {
-			q.queue.Push(data)
+func (b *broker[T]) PublishAfter(topic string, data T, delay time.Duration) {
+	msg := b.stamp(Message[T]{Topic: topic, Payload: data})
+	for _, qm := range b.match(topic) {
+		env, opts := qm.envelope(msg)
+		qm.queue.PushAfter(env, delay, opts...)
+	}
+}
+
+func (b *broker[T]) PublishContext(ctx context.Context, topic string, data T) error {
+	if b.State() != Running {
+		return ErrBrokerClosed
+	}
+
+	msg := b.stamp(Message[T]{Topic: topic, Payload: data})
+	for _, qm := range b.match(topic) {
+		// A full or closed subscriber rejecting the data must not keep it from the others
+		env, opts := qm.envelope(msg)
+		err := qm.queue.PushContext(ctx, env, opts...)
+		if err != nil && !errors.Is(err, queue.ErrFull) && !errors.Is(err, queue.ErrClosed) {
+			return err
 		}
 	}
+
+	return nil
 }
 
-func (b *broker) Subscribe(matcher Matcher) Poller {
+// stamp assigns an ID and a Timestamp to the message if they are not set
+func (b *broker[T]) stamp(msg Message[T]) Message[T] {
+	if msg.ID == "" {
+		msg.ID = strconv.FormatUint(b.sequence.Add(1), 10)
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	return msg
+}
+
+// match returns the subscriptions of the current table matching the topic, caching the result
+func (b *broker[T]) match(topic string) []*queueMatcher[T] {
+	t := b.table.Load()
+	if matched, ok := b.matchCache.get(topic, t.generation); ok {
+		return matched
+	}
+
+	matched := t.lookup(topic)
+	b.matchCache.put(topic, matched, t.generation)
+
+	return matched
+}
+
+func (b *broker[T]) Subscribe(matcher Matcher, opts ...queue.Option) Subscription[T] {
 	b.Lock()
 	defer b.Unlock()
 
-	q := queue.New()
-	b.queueMatchers = append(b.queueMatchers, queueMatcher{queue: q, matcher: matcher})
+	return b.subscribe(matcher, opts)
+}
 
-	b.matchCache = make(map[string]map[Matcher]bool)
+func (b *broker[T]) SubscribeE(matcher Matcher, opts ...queue.Option) (Subscription[T], error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.State() != Running {
+		return nil, ErrBrokerClosed
+	}
+	return b.subscribe(matcher, opts), nil
+}
+
+// subscribe creates a plain subscription, the caller must hold the lock
+func (b *broker[T]) subscribe(matcher Matcher, opts []queue.Option) Subscription[T] {
+	qm := b.add(matcher, "", opts, nil)
 
-	return q
+	return &subscription[T]{queue: qm.queue, id: qm.id, queueID: qm.id, broker: b}
 }
 
-func (b *broker) CloseTopic(matcher Matcher, timeOut time.Duration) {
+func (b *broker[T]) SubscribeGroup(group string, matcher Matcher, opts ...queue.Option) Subscription[T] {
 	b.Lock()
 	defer b.Unlock()
 
-	for i, qm := range b.queueMatchers {
-		if qm.matcher == matcher {
-			qm.queue.Close(timeOut)
-			b.queueMatchers = append(b.queueMatchers[:i], b.queueMatchers[i+1:]...)
-			break
+	return b.subscribeGroup(group, matcher, opts)
+}
+
+func (b *broker[T]) SubscribeGroupE(group string, matcher Matcher, opts ...queue.Option) (Subscription[T], error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.State() != Running {
+		return nil, ErrBrokerClosed
+	}
+	return b.subscribeGroup(group, matcher, opts), nil
+}
+
+// subscribeGroup joins the group, creating its queue for the first member, the caller must hold the lock
+func (b *broker[T]) subscribeGroup(group string, matcher Matcher, opts []queue.Option) Subscription[T] {
+	for _, qm := range b.table.Load().queueMatchers {
+		// An empty group name never joins, the subscription is a plain one
+		if group != "" && qm.group == group && equalMatchers(qm.matcher, matcher) {
+			b.nextID++
+			qm.members++
+			return &subscription[T]{queue: qm.queue, id: b.nextID, queueID: qm.id, broker: b}
 		}
 	}
+
+	qm := b.add(matcher, group, opts, nil)
+
+	return &subscription[T]{queue: qm.queue, id: qm.id, queueID: qm.id, broker: b}
+}
+
+func (b *broker[T]) SubscribeAck(matcher Matcher, opts ...AckOption) AckSubscription[T] {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.subscribeAck(matcher, opts)
+}
+
+func (b *broker[T]) SubscribeAckE(matcher Matcher, opts ...AckOption) (AckSubscription[T], error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.State() != Running {
+		return nil, ErrBrokerClosed
+	}
+	return b.subscribeAck(matcher, opts), nil
+}
+
+// subscribeAck creates an acknowledged subscription, the caller must hold the lock
+func (b *broker[T]) subscribeAck(matcher Matcher, opts []AckOption) AckSubscription[T] {
+	o := ackOptions{visibilityTimeout: DefaultVisibilityTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The expiry handler only uses the broker and the options, the queue is set once created
+	sub := newAckSubscription(&subscription[T]{broker: b}, o)
+
+	qm := b.add(matcher, "", o.queueOptions, sub)
+	sub.queue, sub.id, sub.queueID = qm.queue, qm.id, qm.id
+
+	return sub
+}
+
+// add creates the queue of a new subscription and publishes the table holding it, the caller must hold the lock
+// ack is the acknowledged subscription reading the queue if any, its queue calls ack.expire
+// with the expired messages from before any message is published to it
+func (b *broker[T]) add(matcher Matcher, group string, opts []queue.Option, ack *ackSubscription[T]) *queueMatcher[T] {
+	b.nextID++
+	qm := &queueMatcher[T]{
+		id:      b.nextID,
+		queue:   queue.New[envelope[T]](append(append([]queue.Option{}, b.options.defaultQueueOptions...), opts...)...),
+		matcher: matcher,
+		ack:     ack,
+		group:   group,
+		members: 1,
+	}
+	if ack != nil {
+		qm.queue.OnExpiry(func(env envelope[T]) {
+			ack.expire(env)
+		})
+	}
+	if b.State() != Running {
+		// A stopped broker hands out closed subscriptions, their Poll returns at once
+		qm.queue.Close(-1)
+		return qm
+	}
+
+	t := b.table.Load().with(qm)
+	b.table.Store(t)
+
+	// Only the cached topics matched by the new subscription change, its id is the greatest so it goes last
+	b.matchCache.update(t.generation, func(topic string, matched []*queueMatcher[T]) ([]*queueMatcher[T], bool) {
+		if !matcher.MatchString(topic) {
+			return nil, false
+		}
+		return append(matched[:len(matched):len(matched)], qm), true
+	})
+
+	return qm
 }
 
-func (b *broker) Close(timeOut time.Duration) {
+func (b *broker[T]) CloseTopic(matcher Matcher, timeOut time.Duration) {
 	b.Lock()
 	defer b.Unlock()
-	for _, qm := range b.queueMatchers {
+
+	b.remove(func(qm *queueMatcher[T]) bool {
+		return equalMatchers(qm.matcher, matcher)
+	}, timeOut)
+}
+
+// leave removes a member from the subscription with the id, closing it once the last member left
+func (b *broker[T]) leave(id uint64, timeOut time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.remove(func(qm *queueMatcher[T]) bool {
+		if qm.id != id {
+			return false
+		}
+		qm.members--
+		return qm.members <= 0
+	}, timeOut)
+}
+
+// remove closes and removes the subscriptions for which drop returns true, the caller must hold the lock
+func (b *broker[T]) remove(drop func(*queueMatcher[T]) bool, timeOut time.Duration) {
+	t, dropped := b.table.Load().without(drop)
+	if len(dropped) == 0 {
+		return
+	}
+
+	// Publishers loading the new table no longer see the dropped subscriptions
+	b.table.Store(t)
+	for _, qm := range dropped {
 		qm.queue.Close(timeOut)
 	}
 
-	b.queueMatchers = []queueMatcher{}
+	// Only the cached topics matched by a removed subscription change
+	b.matchCache.update(t.generation, func(_ string, matched []*queueMatcher[T]) ([]*queueMatcher[T], bool) {
+		for i, qm := range matched {
+			if _, ok := t.byID.get(qm.id); !ok {
+				return prune(matched, i, t.byID), true
+			}
+		}
+		return nil, false
+	})
+}
+
+// prune returns a copy of the matched subscriptions without the ones missing from byID, starting at i
+func prune[T any](matched []*queueMatcher[T], i int, byID pmap[uint64, *queueMatcher[T]]) []*queueMatcher[T] {
+	pruned := append(make([]*queueMatcher[T], 0, len(matched)-1), matched[:i]...)
+	for _, qm := range matched[i+1:] {
+		if _, ok := byID.get(qm.id); ok {
+			pruned = append(pruned, qm)
+		}
+	}
+
+	return pruned
 }
 
-// NewBroker creates an instance of broker
-func NewBroker() Broker {
-	return &broker{
-		queueMatchers: []queueMatcher{},
-		matchCache:    make(map[string]map[Matcher]bool),
+func (b *broker[T]) Stats() Stats {
+	// The lock keeps the members of the groups from changing
+	b.Lock()
+	defer b.Unlock()
+
+	stats := Stats{MatchCache: b.matchCache.stats()}
+	for _, qm := range b.table.Load().queueMatchers {
+		stats.add(SubscriptionStats{
+			ID:      qm.id,
+			Matcher: qm.matcher,
+			Group:   qm.group,
+			Members: qm.members,
+			Stats:   qm.queue.Stats(),
+		})
 	}
+
+	return stats
+}
+
+func (b *broker[T]) Close(timeOut time.Duration) {
+	previous, queueMatchers := b.stop(Closed)
+	if previous == Closed {
+		return
+	}
+
+	if previous == Running {
+		for _, qm := range queueMatchers {
+			qm.queue.Close(timeOut)
+		}
+	}
+	b.finish()
+}
+
+// NewBroker creates an instance of broker carrying payloads of type T
+func NewBroker[T any](opts ...Option) Broker[T] {
+	o := options{matchCacheLimit: DefaultMatchCacheLimit}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b := &broker[T]{
+		matchCache: newMatchCache[T](o.matchCacheLimit, o.matchCacheDisabled),
+		options:    o,
+		done:       make(chan struct{}),
+	}
+	b.table.Store(newTable[T](0))
+
+	return b
 }
@@ -17,7 +17,7 @@ import (
 
 func benchmarkPublishToNSubscribers(b *testing.B, n int) {
 
-	broker := NewBroker()
+	broker := NewBroker[int]()
 	defer broker.Close(0)
 
 	reg := regexp.MustCompile(`test\.*`)
@@ -43,7 +43,7 @@ func benchmarkPublishToNSubscribers(b *testing.B, n int) {
 func benchmarkSubscribeToNPublishers(b *testing.B, n int) {
 	var wg sync.WaitGroup
 
-	broker := NewBroker()
+	broker := NewBroker[int]()
 	defer broker.Close(0)
 
 	stop := make(chan bool)
@@ -83,11 +83,11 @@ func benchmarkSubscribeToNPublishers(b *testing.B, n int) {
 func benchmarkMSubscriberNPublisher(b *testing.B, m, n int) {
 	var wg sync.WaitGroup
 
-	broker := NewBroker()
+	broker := NewBroker[int]()
 	defer broker.Close(0)
 
 	stop := make(chan bool)
-	subscriber := make([]Poller, m)
+	subscriber := make([]Poller[int], m)
 	for i := 0; i < m; i++ {
 		subscriber[i] = broker.Subscribe(regexp.MustCompile(`test\.*`))
 	}
@@ -211,3 +211,111 @@ func BenchmarkMultiSubscriber(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkPublishUncachedTopics(b *testing.B) {
+	// Every publish misses the cache, so the subscriptions are found through the index
+
+	for _, subscriberCount := range []int{10, 1000} {
+		b.Run(fmt.Sprintf("Subscribers=%d", subscriberCount), func(b *testing.B) {
+			broker := NewBroker[int](WithMatchCacheLimit(1))
+			defer broker.Close(0)
+
+			for i := 0; i < subscriberCount; i++ {
+				broker.Subscribe(MustTopicMatcher("user." + strconv.Itoa(i) + ".*"))
+			}
+			broker.Publish("warmup", 0)
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				broker.Publish("user."+strconv.Itoa(subscriberCount+i)+".created", i)
+			}
+		})
+	}
+}
+
+func BenchmarkPublishBatch(b *testing.B) {
+	// Each op moves a single message from the publisher to a subscriber, in batches of the given size
+
+	for _, batchSize := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("Size=%d", batchSize), func(b *testing.B) {
+			broker := NewBroker[int]()
+			defer broker.Close(0)
+
+			subscriber := broker.Subscribe(ExactMatcher("test"))
+			data := make([]int, batchSize)
+
+			b.ResetTimer()
+
+			for moved := 0; moved < b.N; moved += batchSize {
+				if batchSize == 1 {
+					broker.Publish("test", data[0])
+					subscriber.Poll()
+					continue
+				}
+
+				broker.PublishBatch("test", data)
+				for polled := 0; polled < batchSize; {
+					polled += len(subscriber.PollBatch(batchSize-polled, -1))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPublishParallel(b *testing.B) {
+	// Publishers on every core route cached topics, none of which is subscribed so that only the routing is measured
+
+	for _, topicCount := range []int{1, 1000} {
+		b.Run(fmt.Sprintf("Topics=%d", topicCount), func(b *testing.B) {
+			broker := NewBroker[int]()
+			defer broker.Close(0)
+
+			for i := 0; i < 100; i++ {
+				broker.Subscribe(MustTopicMatcher("user." + strconv.Itoa(i) + ".*"))
+			}
+
+			topics := make([]string, topicCount)
+			for i := range topics {
+				topics[i] = "order." + strconv.Itoa(i) + ".created"
+				broker.Publish(topics[i], i)
+			}
+
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(topicCount)
+				for pb.Next() {
+					broker.Publish(topics[i%topicCount], i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkSubscribeMany(b *testing.B) {
+	// Each op subscribes the given number of subscriptions to a new broker, one topic each
+
+	for _, subscriptionCount := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("Exact/Subscriptions=%d", subscriptionCount), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				broker := NewBroker[int]()
+				for j := 0; j < subscriptionCount; j++ {
+					broker.Subscribe(ExactMatcher("user." + strconv.Itoa(j)))
+				}
+				broker.Close(0)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Topic/Subscriptions=%d", subscriptionCount), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				broker := NewBroker[int]()
+				for j := 0; j < subscriptionCount; j++ {
+					broker.Subscribe(MustTopicMatcher("user." + strconv.Itoa(j) + ".*"))
+				}
+				broker.Close(0)
+			}
+		})
+	}
+}
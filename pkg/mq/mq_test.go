@@ -1,13 +1,20 @@
 package mq
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
 )
 
 func TestBrokerOnSingleRoutine(t *testing.T) {
-	broker := NewBroker()
+	broker := NewBroker[int]()
 	defer broker.Close(0)
 
 	subscriber := broker.Subscribe(ExactMatcher("test"))
@@ -24,7 +31,7 @@ func TestBrokerOnSingleRoutine(t *testing.T) {
 			if !ok {
 				t.Errorf("Poll on available value should be True got False")
 			}
-			if expected != val.(int) {
+			if expected != val {
 				t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
 			}
 		}
@@ -38,7 +45,7 @@ func TestBrokerOnSingleRoutine(t *testing.T) {
 }
 
 func TestBrokerOnMultiRoutine(t *testing.T) {
-	broker := NewBroker()
+	broker := NewBroker[int32]()
 	defer broker.Close(0)
 
 	subscriber := broker.Subscribe(ExactMatcher("test"))
@@ -62,11 +69,11 @@ func TestBrokerOnMultiRoutine(t *testing.T) {
 				if !ok {
 					t.Errorf("Poll on available value should be True Got False")
 				}
-				if last > val.(int32) {
+				if last > val {
 					t.Errorf("Invalid Value: Last Value: %d Obtained: %v", last, val)
 				}
 
-				last = val.(int32)
+				last = val
 			}
 		}()
 	}
@@ -79,7 +86,7 @@ func TestBrokerOnMultiRoutine(t *testing.T) {
 }
 
 func TestBrokerPollAfterClose(t *testing.T) {
-	broker := NewBroker()
+	broker := NewBroker[string]()
 	subscriber := broker.Subscribe(ExactMatcher("test"))
 	broker.Publish("test", "test value")
 
@@ -112,7 +119,7 @@ func TestBrokerPollAfterClose(t *testing.T) {
 
 func TestCloseBroker(t *testing.T) {
 
-	broker := NewBroker()
+	broker := NewBroker[string]()
 
 	topics := []string{"topic1", "topic2", "topic3"}
 
@@ -126,7 +133,7 @@ func TestCloseBroker(t *testing.T) {
 			incr := 0
 			for val, ok := subscriber.Poll(); ok; val, ok = subscriber.Poll() {
 				expected := fmt.Sprintf("%s:%v", topic, incr)
-				if expected != val.(string) {
+				if expected != val {
 					t.Errorf("Invalid Value: Expected: %v Obtained: %v", expected, val)
 				}
 				incr++
@@ -144,3 +151,1005 @@ func TestCloseBroker(t *testing.T) {
 	broker.Close(-1)
 	wg.Wait()
 }
+
+func TestBrokerTypedPayload(t *testing.T) {
+	type order struct {
+		id     int
+		amount float64
+	}
+
+	broker := NewBroker[order]()
+	defer broker.Close(0)
+
+	subscriber := broker.Subscribe(ExactMatcher("orders"))
+
+	expected := order{id: 42, amount: 9.99}
+	broker.Publish("orders", expected)
+
+	val, ok := subscriber.Poll()
+	if !ok {
+		t.Fatal("Poll on available value should be True got False")
+	}
+	if val != expected {
+		t.Errorf("Invalid Value: Expected: %v Obtained: %v", expected, val)
+	}
+}
+
+func TestBrokerPollContext(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := subscriber.PollContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", context.DeadlineExceeded, err)
+	}
+
+	if err := broker.PublishContext(context.Background(), "test", 1); err != nil {
+		t.Fatalf("PublishContext should succeed, Obtained: %v", err)
+	}
+
+	val, err := subscriber.PollContext(context.Background())
+	if err != nil {
+		t.Fatalf("PollContext on available value should succeed, Obtained: %v", err)
+	}
+	if val != 1 {
+		t.Errorf("Invalid Value: Expected: 1 Obtained: %v", val)
+	}
+
+	broker.Close(-1)
+
+	if _, err := subscriber.PollContext(context.Background()); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", queue.ErrClosed, err)
+	}
+}
+
+func TestBrokerPublishContextCancelled(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := broker.PublishContext(ctx, "test", 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", context.Canceled, err)
+	}
+
+	broker.Publish("test", 2)
+
+	val, ok := subscriber.Poll()
+	if !ok || val != 2 {
+		t.Errorf("Invalid Value: Expected: 2 Obtained: %v", val)
+	}
+}
+
+func TestBrokerTryPollAndPollTimeout(t *testing.T) {
+	broker := NewBroker[string]()
+
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+
+	if _, ok, available := subscriber.TryPoll(); !ok || available {
+		t.Errorf("TryPoll on empty subscriber: Expected: ok=true available=false Obtained: ok=%v available=%v", ok, available)
+	}
+
+	broker.Publish("test", "first")
+	broker.Publish("test", "second")
+
+	if val, _, available := subscriber.TryPoll(); !available || val != "first" {
+		t.Errorf("Invalid Value: Expected: first Obtained: %v", val)
+	}
+	if val, _, available := subscriber.PollTimeout(time.Second); !available || val != "second" {
+		t.Errorf("Invalid Value: Expected: second Obtained: %v", val)
+	}
+	if _, ok, available := subscriber.PollTimeout(time.Millisecond); !ok || available {
+		t.Errorf("PollTimeout on empty subscriber: Expected: ok=true available=false Obtained: ok=%v available=%v", ok, available)
+	}
+
+	broker.Close(-1)
+
+	if _, ok, _ := subscriber.TryPoll(); ok {
+		t.Error("TryPoll on closed subscriber should be False")
+	}
+}
+
+func TestBrokerBoundedSubscriber(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	bounded := broker.Subscribe(ExactMatcher("test"), queue.WithCapacity(1), queue.WithOverflowPolicy(queue.DropOldest))
+	unbounded := broker.Subscribe(ExactMatcher("test"))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("test", i)
+	}
+
+	if val, _, available := bounded.TryPoll(); !available || val != 2 {
+		t.Errorf("Invalid Value: Expected: 2 Obtained: %v", val)
+	}
+	if _, _, available := bounded.TryPoll(); available {
+		t.Error("Bounded subscriber should hold a single value")
+	}
+
+	for expected := 0; expected < 3; expected++ {
+		if val, _, available := unbounded.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+}
+
+func TestBrokerDefaultQueueOptions(t *testing.T) {
+	broker := NewBroker[int](WithDefaultQueueOptions(queue.WithCapacity(1), queue.WithOverflowPolicy(queue.DropOldest)))
+	defer broker.Close(0)
+
+	bounded := broker.Subscribe(ExactMatcher("test"))
+	unbounded := broker.Subscribe(ExactMatcher("test"), queue.WithCapacity(0))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("test", i)
+	}
+
+	if val, _, available := bounded.TryPoll(); !available || val != 2 {
+		t.Errorf("Invalid Value: Expected: 2 Obtained: %v", val)
+	}
+	if _, _, available := bounded.TryPoll(); available {
+		t.Error("Subscriber with default options should hold a single value")
+	}
+
+	for expected := 0; expected < 3; expected++ {
+		if val, _, available := unbounded.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+}
+
+func TestBrokerMatchCacheLimit(t *testing.T) {
+	limit := 5
+	b := NewBroker[int](WithMatchCacheLimit(limit))
+	defer b.Close(0)
+
+	subscriber := b.Subscribe(regexp.MustCompile(`^user\.\d+$`))
+
+	maxCount := 20
+	for i := 0; i < maxCount; i++ {
+		b.Publish(fmt.Sprintf("user.%d", i), i)
+	}
+
+	for expected := 0; expected < maxCount; expected++ {
+		if val, _, available := subscriber.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+
+	stats := b.Stats().MatchCache
+	if stats.Size != limit || stats.Limit != limit {
+		t.Errorf("Invalid Cache Size: Expected: %d Obtained: %d of %d", limit, stats.Size, stats.Limit)
+	}
+	if stats.Misses != uint64(maxCount) || stats.Evictions != uint64(maxCount-limit) {
+		t.Errorf("Invalid Cache Stats: Expected: %d misses and %d evictions Obtained: %+v", maxCount, maxCount-limit, stats)
+	}
+}
+
+func TestBrokerMatchCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	b := NewBroker[int](WithMatchCacheLimit(2))
+	defer b.Close(0)
+
+	subscriber := b.Subscribe(MustTopicMatcher("user.*"))
+
+	b.Publish("user.1", 1)
+	b.Publish("user.2", 2)
+	b.Publish("user.1", 3)
+	// user.2 is the least recently used topic
+	b.Publish("user.3", 4)
+	b.Publish("user.1", 5)
+
+	if stats := b.Stats().MatchCache; stats.Hits != 2 || stats.Misses != 3 || stats.Evictions != 1 {
+		t.Errorf("Invalid Cache Stats: Expected: 2 hits, 3 misses and 1 eviction Obtained: %+v", stats)
+	}
+	for expected := 1; expected <= 5; expected++ {
+		if val, _, _ := subscriber.TryPoll(); val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+}
+
+func TestBrokerWithoutMatchCache(t *testing.T) {
+	b := NewBroker[int](WithoutMatchCache())
+	defer b.Close(0)
+
+	subscriber := b.Subscribe(ExactMatcher("test"))
+	b.Publish("test", 1)
+	b.Publish("test", 2)
+
+	if stats := b.Stats().MatchCache; !stats.Disabled || stats.Size != 0 || stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("Invalid Cache Stats: Expected: disabled with 2 misses Obtained: %+v", stats)
+	}
+	if length := subscriber.Len(); length != 2 {
+		t.Errorf("Invalid Len: Expected: 2 Obtained: %d", length)
+	}
+}
+
+func TestBrokerStats(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.Subscribe(ExactMatcher("first"))
+	second := broker.Subscribe(ExactMatcher("second"))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("first", i)
+	}
+	broker.Publish("second", 0)
+
+	first.Poll()
+
+	if length := first.Len(); length != 2 {
+		t.Errorf("Invalid Len: Expected: 2 Obtained: %d", length)
+	}
+	if length := second.Len(); length != 1 {
+		t.Errorf("Invalid Len: Expected: 1 Obtained: %d", length)
+	}
+
+	stats := broker.Stats()
+	if len(stats.Subscriptions) != 2 {
+		t.Fatalf("Invalid Subscription Count: Expected: 2 Obtained: %d", len(stats.Subscriptions))
+	}
+	if stats.Enqueued != 4 || stats.Dequeued != 1 || stats.Depth != 3 || stats.MaxDepth != 2 {
+		t.Errorf("Invalid Stats: %+v", stats)
+	}
+	if stats.Subscriptions[0].Matcher != ExactMatcher("first") || stats.Subscriptions[0].Depth != 2 {
+		t.Errorf("Invalid Subscription Stats: %+v", stats.Subscriptions[0])
+	}
+}
+
+func TestSubscriptionUnsubscribe(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.Subscribe(ExactMatcher("orders"))
+	second := broker.Subscribe(ExactMatcher("orders"))
+
+	if first.ID() == second.ID() {
+		t.Fatalf("Subscriptions should have distinct IDs, both are %d", first.ID())
+	}
+
+	broker.Publish("orders", 1)
+	first.Unsubscribe(-1)
+	broker.Publish("orders", 2)
+
+	if val, ok := first.Poll(); !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1 Obtained: %v", val)
+	}
+	if _, ok := first.Poll(); ok {
+		t.Error("Poll on unsubscribed subscription should be False")
+	}
+	if !first.IsClosed() {
+		t.Error("Unsubscribed subscription should be closed")
+	}
+
+	for expected := 1; expected <= 2; expected++ {
+		if val, _, available := second.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+
+	if subscriptions := broker.Stats().Subscriptions; len(subscriptions) != 1 || subscriptions[0].ID != second.ID() {
+		t.Errorf("Invalid Subscriptions: Expected only %d Obtained: %+v", second.ID(), subscriptions)
+	}
+
+	// Unsubscribing twice is a no-op
+	first.Unsubscribe(-1)
+}
+
+func TestCloseTopicClosesEveryMatchingSubscription(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.Subscribe(ExactMatcher("orders"))
+	second := broker.Subscribe(ExactMatcher("orders"))
+	other := broker.Subscribe(ExactMatcher("payments"))
+
+	broker.Publish("orders", 1)
+	broker.CloseTopic(ExactMatcher("orders"), -1)
+	broker.Publish("orders", 2)
+	broker.Publish("payments", 3)
+
+	for _, subscriber := range []Subscription[int]{first, second} {
+		if val, ok := subscriber.Poll(); !ok || val != 1 {
+			t.Errorf("Invalid Value: Expected: 1 Obtained: %v", val)
+		}
+		if _, ok := subscriber.Poll(); ok {
+			t.Error("Poll on closed topic should be False")
+		}
+	}
+
+	if val, ok := other.Poll(); !ok || val != 3 {
+		t.Errorf("Invalid Value: Expected: 3 Obtained: %v", val)
+	}
+}
+
+func TestSubscribeGroup(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	workers := []Subscription[int]{
+		broker.SubscribeGroup("workers", ExactMatcher("jobs")),
+		broker.SubscribeGroup("workers", ExactMatcher("jobs")),
+		broker.SubscribeGroup("workers", ExactMatcher("jobs")),
+	}
+	auditors := broker.SubscribeGroup("auditors", ExactMatcher("jobs"))
+	logger := broker.Subscribe(ExactMatcher("jobs"))
+
+	maxCount := 300
+
+	received := make([][]int, len(workers))
+	wg := sync.WaitGroup{}
+	for i, worker := range workers {
+		i, worker := i, worker
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for val, ok := worker.Poll(); ok; val, ok = worker.Poll() {
+				received[i] = append(received[i], val)
+			}
+		}()
+	}
+
+	for i := 0; i < maxCount; i++ {
+		broker.Publish("jobs", i)
+	}
+
+	for _, subscriber := range []Subscription[int]{auditors, logger} {
+		for expected := 0; expected < maxCount; expected++ {
+			if val, _, available := subscriber.TryPoll(); !available || val != expected {
+				t.Fatalf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+			}
+		}
+	}
+
+	broker.CloseTopic(ExactMatcher("jobs"), -1)
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, values := range received {
+		for _, val := range values {
+			if seen[val] {
+				t.Errorf("Value %d delivered to more than one member of the group", val)
+			}
+			seen[val] = true
+		}
+	}
+	if len(seen) != maxCount {
+		t.Errorf("Invalid Delivered Count: Expected: %d Obtained: %d", maxCount, len(seen))
+	}
+}
+
+// prefixesMatcher is a Matcher which is not comparable
+type prefixesMatcher struct {
+	prefixes []string
+}
+
+func (m prefixesMatcher) MatchString(topic string) bool {
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubscribeGroupUncomparableMatcher(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	matcher := prefixesMatcher{prefixes: []string{"jobs."}}
+	first := broker.SubscribeGroup("workers", matcher)
+	second := broker.SubscribeGroup("workers", matcher)
+
+	if subscriptions := broker.Stats().Subscriptions; len(subscriptions) != 2 {
+		t.Fatalf("Invalid Subscriptions: Expected: 2 queues Obtained: %+v", subscriptions)
+	}
+
+	broker.Publish("jobs.a", 1)
+	for _, sub := range []Subscription[int]{first, second} {
+		if val, _, available := sub.TryPoll(); !available || val != 1 {
+			t.Errorf("Invalid Value: Expected: 1 Obtained: %v", val)
+		}
+	}
+
+	broker.CloseTopic(matcher, -1)
+	if first.IsClosed() || second.IsClosed() {
+		t.Error("CloseTopic should not close the subscriptions of an uncomparable matcher")
+	}
+}
+
+func TestSubscribeGroupUnsubscribe(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.SubscribeGroup("workers", ExactMatcher("jobs"))
+	second := broker.SubscribeGroup("workers", ExactMatcher("jobs"))
+
+	if first.ID() == second.ID() {
+		t.Fatalf("Group members should have distinct IDs, both are %d", first.ID())
+	}
+
+	stats := broker.Stats()
+	if len(stats.Subscriptions) != 1 || stats.Subscriptions[0].Members != 2 || stats.Subscriptions[0].Group != "workers" {
+		t.Fatalf("Invalid Subscriptions: %+v", stats.Subscriptions)
+	}
+
+	first.Unsubscribe(-1)
+	first.Unsubscribe(-1)
+
+	broker.Publish("jobs", 1)
+	if val, ok := second.Poll(); !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1 Obtained: %v", val)
+	}
+
+	second.Unsubscribe(-1)
+	if _, ok := second.Poll(); ok {
+		t.Error("Poll after the last member left should be False")
+	}
+	if subscriptions := broker.Stats().Subscriptions; len(subscriptions) != 0 {
+		t.Errorf("Invalid Subscriptions: Expected none Obtained: %+v", subscriptions)
+	}
+}
+
+func TestPollMessage(t *testing.T) {
+	broker := NewBroker[string]()
+	defer broker.Close(0)
+
+	subscriber := broker.Subscribe(regexp.MustCompile(`tests\.\w*`))
+
+	before := time.Now()
+	broker.Publish("tests.first", "first")
+	broker.PublishMessage(Message[string]{
+		ID:      "custom-id",
+		Topic:   "tests.second",
+		Headers: map[string]string{"trace-id": "abc"},
+		Payload: "second",
+	})
+
+	first, ok := subscriber.PollMessage()
+	if !ok {
+		t.Fatal("PollMessage on available value should be True got False")
+	}
+	if first.Topic != "tests.first" || first.Payload != "first" {
+		t.Errorf("Invalid Message: %+v", first)
+	}
+	if first.ID == "" || first.Timestamp.Before(before) {
+		t.Errorf("Broker should assign an ID and a Timestamp: %+v", first)
+	}
+
+	second, ok := subscriber.PollMessage()
+	if !ok {
+		t.Fatal("PollMessage on available value should be True got False")
+	}
+	if second.ID != "custom-id" || second.Topic != "tests.second" || second.Header("trace-id") != "abc" {
+		t.Errorf("Invalid Message: %+v", second)
+	}
+	if second.ID == first.ID {
+		t.Errorf("Messages should have distinct IDs, both are %s", first.ID)
+	}
+}
+
+func TestPollMessageHeadersCopied(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.Subscribe(ExactMatcher("test"))
+	second := broker.Subscribe(ExactMatcher("test"))
+
+	headers := map[string]string{"trace-id": "abc"}
+	broker.PublishMessage(Message[int]{Topic: "test", Headers: headers, Payload: 1})
+	headers["trace-id"] = "publisher"
+
+	msg, _ := first.PollMessage()
+	msg.Headers["trace-id"] = "first"
+
+	if msg, _ := second.PollMessage(); msg.Header("trace-id") != "abc" {
+		t.Errorf("Invalid Header: Expected: abc Obtained: %s", msg.Header("trace-id"))
+	}
+}
+
+func TestBrokerPrioritySubscription(t *testing.T) {
+	broker := NewBroker[string]()
+	defer broker.Close(0)
+
+	prioritized := broker.Subscribe(ExactMatcher("jobs"), queue.WithPriority())
+	fifo := broker.Subscribe(ExactMatcher("jobs"))
+
+	broker.Publish("jobs", "bulk-1")
+	broker.Publish("jobs", "bulk-2")
+	broker.PublishMessage(Message[string]{Topic: "jobs", Priority: 10, Payload: "urgent"})
+
+	for _, expected := range []string{"urgent", "bulk-1", "bulk-2"} {
+		if val, _, available := prioritized.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %s Obtained: %v", expected, val)
+		}
+	}
+
+	for _, expected := range []string{"bulk-1", "bulk-2", "urgent"} {
+		if val, _, available := fifo.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %s Obtained: %v", expected, val)
+		}
+	}
+}
+
+func TestBrokerPublishAfter(t *testing.T) {
+	broker := NewBroker[string]()
+	defer broker.Close(0)
+
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+
+	start := time.Now()
+	broker.PublishAfter("test", "later", 20*time.Millisecond)
+	broker.Publish("test", "now")
+
+	for _, expected := range []string{"now", "later"} {
+		val, _, available := subscriber.PollTimeout(time.Second)
+		if !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %s Obtained: %v", expected, val)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Delayed message delivered after %v, before its delay", elapsed)
+	}
+}
+
+func TestBrokerTTL(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	subscriber := broker.Subscribe(ExactMatcher("test"), queue.WithTTL(10*time.Millisecond))
+
+	broker.Publish("test", 1)
+	broker.PublishMessage(Message[int]{Topic: "test", TTL: time.Hour, Payload: 2})
+	time.Sleep(20 * time.Millisecond)
+
+	if val, _, available := subscriber.TryPoll(); !available || val != 2 {
+		t.Errorf("Invalid Value: Expected: 2, Obtained: %v", val)
+	}
+	if expired := broker.Stats().Subscriptions[0].Expired; expired != 1 {
+		t.Errorf("Invalid Expired: Expected: 1, Obtained: %d", expired)
+	}
+	if expired := broker.Stats().Expired; expired != 1 {
+		t.Errorf("Invalid Total Expired: Expected: 1, Obtained: %d", expired)
+	}
+}
+
+func TestSubscriptionOnExpiry(t *testing.T) {
+	broker := NewBroker[int](WithDefaultQueueOptions(queue.WithTTL(10 * time.Millisecond)))
+	defer broker.Close(0)
+
+	expired := make(chan Message[int], 1)
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+	subscriber.OnExpiry(func(msg Message[int]) {
+		expired <- msg
+	})
+
+	broker.PublishMessage(Message[int]{Topic: "test", Headers: map[string]string{"trace-id": "abc"}, Payload: 1})
+
+	select {
+	case msg := <-expired:
+		if msg.Payload != 1 || msg.Topic != "test" || msg.Header("trace-id") != "abc" {
+			t.Errorf("Invalid Message: %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expired message should be passed to the handler")
+	}
+}
+
+func TestMatchCacheUpdatedIncrementally(t *testing.T) {
+	b := NewBroker[int]()
+	defer b.Close(0)
+
+	first := b.Subscribe(MustTopicMatcher("orders.#"))
+	b.Publish("orders.created", 1)
+	b.Publish("users.created", 2)
+
+	// Subscribing and unsubscribing keep the cached topics
+	second := b.Subscribe(MustTopicMatcher("*.created"))
+	if cached := b.Stats().MatchCache.Size; cached != 2 {
+		t.Errorf("Invalid Cache Length: Expected: 2, Obtained: %d", cached)
+	}
+
+	b.Publish("orders.created", 3)
+	b.Publish("users.created", 4)
+	first.Unsubscribe(-1)
+	b.Publish("orders.created", 5)
+
+	if cached := b.Stats().MatchCache.Size; cached != 2 {
+		t.Errorf("Invalid Cache Length: Expected: 2, Obtained: %d", cached)
+	}
+	for _, expected := range []int{1, 3} {
+		if val, _ := first.Poll(); val != expected {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v", expected, val)
+		}
+	}
+	for _, expected := range []int{3, 4, 5} {
+		if val, _, _ := second.TryPoll(); val != expected {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v", expected, val)
+		}
+	}
+}
+
+func TestBrokerConcurrentPublishAndSubscribe(t *testing.T) {
+	broker := NewBroker[int](WithMatchCacheLimit(8))
+	defer broker.Close(0)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				topic := fmt.Sprintf("orders.%d.created", (i+n)%16)
+				switch n % 3 {
+				case 0:
+					broker.Publish(topic, n)
+				case 1:
+					broker.PublishAfter(topic, n, time.Millisecond)
+				default:
+					ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+					broker.PublishContext(ctx, topic, n)
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				var sub Subscription[int]
+				switch n % 4 {
+				case 0:
+					sub = broker.Subscribe(MustTopicMatcher("orders.*.created"))
+				case 1:
+					// A full blocking subscriber must not keep the publishers from unsubscribing it
+					sub = broker.Subscribe(ExactMatcher(fmt.Sprintf("orders.%d.created", i)), queue.WithCapacity(1))
+				case 2:
+					sub = broker.SubscribeGroup("workers", MustTopicMatcher("orders.#"))
+				default:
+					sub = broker.Subscribe(regexp.MustCompile(`^orders\.\d+\.created$`))
+				}
+				sub.TryPoll()
+				broker.Stats()
+
+				if n%8 == 7 {
+					broker.CloseTopic(MustTopicMatcher("orders.*.created"), 0)
+				}
+				sub.Unsubscribe(0)
+			}
+		}()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+}
+
+func TestBrokerCloseReleasesBlockedPublisher(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.Subscribe(ExactMatcher("test"), queue.WithCapacity(1))
+	other := broker.Subscribe(ExactMatcher("test"))
+	broker.Publish("test", 1)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		broker.Publish("test", 2)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("Publish should block on the full subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	subscriber.Unsubscribe(-1)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe should release the blocked Publish")
+	}
+
+	for _, expected := range []int{1, 2} {
+		if val, _, _ := other.TryPoll(); val != expected {
+			t.Errorf("Invalid Value: Expected: %d Obtained: %v", expected, val)
+		}
+	}
+	broker.Close(-1)
+	broker.Publish("test", 3)
+}
+
+func TestBrokerPublishBatch(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	first := broker.Subscribe(ExactMatcher("test"))
+	second := broker.Subscribe(MustTopicMatcher("*"))
+
+	broker.PublishBatch("test", []int{1, 2, 3})
+	broker.PublishBatch("test", nil)
+
+	if batch := first.PollBatch(10, 0); len(batch) != 3 || batch[0] != 1 || batch[1] != 2 || batch[2] != 3 {
+		t.Errorf("Invalid Batch: Expected: [1 2 3] Obtained: %v", batch)
+	}
+
+	ids := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		msg, _ := second.PollMessage()
+		if msg.Topic != "test" || msg.Payload != i+1 {
+			t.Errorf("Invalid Message: %+v", msg)
+		}
+		ids[msg.ID] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("Every message of a batch should have its own ID, Obtained: %v", ids)
+	}
+
+	if batch := first.PollBatch(10, 10*time.Millisecond); batch != nil {
+		t.Errorf("Invalid Batch: Expected: [] Obtained: %v", batch)
+	}
+}
+
+func TestBrokerDrain(t *testing.T) {
+	broker := NewBroker[int]()
+
+	subscriber := broker.Subscribe(ExactMatcher("test"))
+	for i := 0; i < 3; i++ {
+		broker.Publish("test", i)
+	}
+
+	polled := make(chan int)
+	go func() {
+		count := 0
+		for _, ok := subscriber.Poll(); ok; _, ok = subscriber.Poll() {
+			count++
+		}
+		polled <- count
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := broker.Drain(ctx); err != nil {
+		t.Errorf("Invalid Error: Expected: <nil> Obtained: %v", err)
+	}
+	if count := <-polled; count != 3 {
+		t.Errorf("Invalid Count: Expected: 3 Obtained: %d", count)
+	}
+	if _, err := broker.PublishE("test", 3); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+}
+
+func TestBrokerShutdown(t *testing.T) {
+	broker := NewBroker[int]()
+
+	first := broker.Subscribe(ExactMatcher("test"))
+	second := broker.SubscribeGroup("workers", MustTopicMatcher("#"))
+	broker.Subscribe(ExactMatcher("other"))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("test", i)
+	}
+	first.Poll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	leftovers, err := broker.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", context.DeadlineExceeded, err)
+	}
+	if len(leftovers) != 2 {
+		t.Fatalf("Invalid Leftovers: Expected: 2 subscriptions Obtained: %+v", leftovers)
+	}
+
+	if leftovers[0].ID != first.ID() || len(leftovers[0].Messages) != 2 || leftovers[0].Messages[0].Payload != 1 {
+		t.Errorf("Invalid Leftover: %+v", leftovers[0])
+	}
+	if leftovers[1].ID != second.ID() || leftovers[1].Group != "workers" || len(leftovers[1].Messages) != 3 {
+		t.Errorf("Invalid Leftover: %+v", leftovers[1])
+	}
+	if msg := leftovers[1].Messages[2]; msg.Topic != "test" || msg.Payload != 2 {
+		t.Errorf("Invalid Message: %+v", msg)
+	}
+
+	if !first.IsClosed() || !second.IsClosed() {
+		t.Error("Shutdown should close every subscription")
+	}
+	if _, ok := first.Poll(); ok {
+		t.Error("Poll on shut down subscription should be False Got True")
+	}
+}
+
+func TestBrokerLifecycle(t *testing.T) {
+	broker := NewBroker[int]()
+	sub, err := broker.SubscribeE(ExactMatcher("test"))
+	if err != nil {
+		t.Fatalf("Invalid Error: Expected: nil Obtained: %v", err)
+	}
+
+	if state := broker.State(); state != Running {
+		t.Errorf("Invalid State: Expected: %v Obtained: %v", Running, state)
+	}
+
+	broker.Publish("test", 1)
+
+	done := make(chan error)
+	go func() {
+		done <- broker.Drain(context.Background())
+	}()
+
+	for broker.State() == Running {
+		time.Sleep(time.Millisecond)
+	}
+	if state := broker.State(); state != Draining {
+		t.Errorf("Invalid State: Expected: %v Obtained: %v", Draining, state)
+	}
+	if err := broker.PublishContext(context.Background(), "test", 2); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+
+	select {
+	case <-broker.Done():
+		t.Fatal("Done should not be closed while draining")
+	default:
+	}
+
+	sub.Poll()
+	if err := <-done; err != nil {
+		t.Fatalf("Invalid Error: Expected: nil Obtained: %v", err)
+	}
+
+	<-broker.Done()
+	if state := broker.State(); state != Closed {
+		t.Errorf("Invalid State: Expected: %v Obtained: %v", Closed, state)
+	}
+
+	if err := broker.Drain(context.Background()); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+	if _, err := broker.Shutdown(context.Background()); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+	if _, err := broker.PublishE("test", 3); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+
+	if _, err := broker.PublishMessageE(Message[int]{Topic: "test", Payload: 3}); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+	if sub, err := broker.SubscribeE(ExactMatcher("test")); sub != nil || !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid SubscribeE: Expected: nil and %v Obtained: %v and %v", ErrBrokerClosed, sub, err)
+	}
+	if sub, err := broker.SubscribeGroupE("workers", ExactMatcher("test")); sub != nil || !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid SubscribeGroupE: Expected: nil and %v Obtained: %v and %v", ErrBrokerClosed, sub, err)
+	}
+	if sub, err := broker.SubscribeAckE(ExactMatcher("test")); sub != nil || !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid SubscribeAckE: Expected: nil and %v Obtained: %v and %v", ErrBrokerClosed, sub, err)
+	}
+
+	late := broker.Subscribe(ExactMatcher("test"))
+	if !late.IsClosed() {
+		t.Error("Subscribe on closed broker should return a closed subscription")
+	}
+	if _, ok := late.Poll(); ok {
+		t.Error("Poll on closed subscription should be False Got True")
+	}
+	if stats := broker.Stats(); len(stats.Subscriptions) != 0 {
+		t.Errorf("Invalid Subscriptions: Expected: 0 Obtained: %d", len(stats.Subscriptions))
+	}
+}
+
+func TestBrokerConcurrentClose(t *testing.T) {
+	broker := NewBroker[int]()
+	sub := broker.Subscribe(ExactMatcher("test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			broker.Close(0)
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-broker.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done should be closed once the broker is closed")
+	}
+	if state := broker.State(); state != Closed {
+		t.Errorf("Invalid State: Expected: %v Obtained: %v", Closed, state)
+	}
+	if !sub.IsClosed() {
+		t.Error("Close should close every subscription")
+	}
+
+	broker.Close(0)
+}
+
+func TestBrokerCloseWhileDraining(t *testing.T) {
+	broker := NewBroker[int]()
+	sub := broker.Subscribe(ExactMatcher("test"))
+	broker.Publish("test", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := broker.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", context.DeadlineExceeded, err)
+	}
+	if state := broker.State(); state != Draining {
+		t.Errorf("Invalid State: Expected: %v Obtained: %v", Draining, state)
+	}
+
+	broker.Close(-1)
+	<-broker.Done()
+
+	if v, ok := sub.Poll(); !ok || v != 1 {
+		t.Errorf("Invalid Poll: Expected: 1 true Obtained: %v %v", v, ok)
+	}
+}
+
+func TestSubscriptionChan(t *testing.T) {
+	broker := NewBroker[int]()
+	first := broker.Subscribe(ExactMatcher("first"))
+	second := broker.Subscribe(ExactMatcher("second"))
+
+	broker.Publish("first", 1)
+	broker.Publish("second", 2)
+
+	received := map[int]bool{}
+	for len(received) < 2 {
+		select {
+		case v := <-first.Chan():
+			received[v] = true
+		case v := <-second.Chan():
+			received[v] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Invalid Received: Expected: 1 and 2 Obtained: %v", received)
+		}
+	}
+
+	broker.Publish("first", 3)
+	first.Unsubscribe(-1)
+
+	var values []int
+	for v := range first.Chan() {
+		values = append(values, v)
+	}
+	if len(values) != 1 || values[0] != 3 {
+		t.Errorf("Invalid Values: Expected: [3] Obtained: %v", values)
+	}
+	broker.Close(0)
+}
@@ -0,0 +1,37 @@
+package mq
+
+import "github.com/Dev-Destructor/go-queue/pkg/queue"
+
+// Option configures a broker created by NewBroker
+type Option func(*options)
+
+// options holds the configuration of a broker
+type options struct {
+	matchCacheLimit     int
+	matchCacheDisabled  bool
+	defaultQueueOptions []queue.Option
+}
+
+// WithMatchCacheLimit limits the number of topics whose matches are cached, DefaultMatchCacheLimit by default
+// Once the limit is reached, caching a topic evicts one which was not published lately, approximating the least recently published one
+// A limit less than or equal to 0 means the cache is unbounded
+func WithMatchCacheLimit(limit int) Option {
+	return func(o *options) {
+		o.matchCacheLimit = limit
+	}
+}
+
+// WithoutMatchCache disables the match cache, the subscriptions matching a topic are looked up on every publish
+func WithoutMatchCache() Option {
+	return func(o *options) {
+		o.matchCacheDisabled = true
+	}
+}
+
+// WithDefaultQueueOptions sets the queue options applied to every subscription
+// The options given to Subscribe are applied after them and take precedence
+func WithDefaultQueueOptions(opts ...queue.Option) Option {
+	return func(o *options) {
+		o.defaultQueueOptions = append(o.defaultQueueOptions, opts...)
+	}
+}
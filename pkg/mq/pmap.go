@@ -0,0 +1,245 @@
+package mq
+
+import "math/bits"
+
+// pmap is a persistent hash map keyed by strings or uint64, its zero value is an empty map
+// Setting or deleting a key returns a new map sharing every node but the ones on the path to the key,
+// so that an update copies O(log n) small nodes and the previous map is left untouched.
+type pmap[K comparable, V any] struct {
+	root *pmapNode[K, V]
+	size int
+}
+
+// pmapNode is a level of the trie of the hashes of the keys, each level consumes pmapBits of the hash
+// Past the last level, the node holds the entries whose hashes are equal in a plain list and its bitmap is unused.
+type pmapNode[K comparable, V any] struct {
+	// bitmap has the bits of the slots in use set, the slots are ordered by bit
+	bitmap uint32
+	slots  []pmapSlot[K, V]
+}
+
+// pmapSlot is either a child node or an entry
+type pmapSlot[K comparable, V any] struct {
+	child *pmapNode[K, V]
+	hash  uint64
+	key   K
+	value V
+}
+
+const (
+	pmapBits = 5
+	pmapMask = 1<<pmapBits - 1
+)
+
+// len returns the number of keys of the map
+func (m pmap[K, V]) len() int {
+	return m.size
+}
+
+// get returns the value of the key
+func (m pmap[K, V]) get(key K) (V, bool) {
+	h := hashKey(key)
+	n := m.root
+	for shift := uint(0); n != nil; shift += pmapBits {
+		if shift >= 64 {
+			for _, s := range n.slots {
+				if s.key == key {
+					return s.value, true
+				}
+			}
+			break
+		}
+
+		bit := uint32(1) << ((h >> shift) & pmapMask)
+		if n.bitmap&bit == 0 {
+			break
+		}
+		s := n.slots[bits.OnesCount32(n.bitmap&(bit-1))]
+		if s.child == nil {
+			if s.key == key {
+				return s.value, true
+			}
+			break
+		}
+		n = s.child
+	}
+
+	var zero V
+	return zero, false
+}
+
+// set returns a copy of the map with the key set to the value
+func (m pmap[K, V]) set(key K, value V) pmap[K, V] {
+	root, added := m.root.set(0, pmapSlot[K, V]{hash: hashKey(key), key: key, value: value})
+	m.root = root
+	if added {
+		m.size++
+	}
+
+	return m
+}
+
+// delete returns a copy of the map without the key, or the map itself if it does not hold the key
+func (m pmap[K, V]) delete(key K) pmap[K, V] {
+	root, removed := m.root.delete(0, hashKey(key), key)
+	if removed {
+		m.root = root
+		m.size--
+	}
+
+	return m
+}
+
+// each calls fn with every key and value of the map, in no particular order
+func (m pmap[K, V]) each(fn func(K, V)) {
+	m.root.each(fn)
+}
+
+// set returns a copy of the node, nil being an empty one, with the entry e and whether its key was added
+func (n *pmapNode[K, V]) set(shift uint, e pmapSlot[K, V]) (*pmapNode[K, V], bool) {
+	if n == nil {
+		n = &pmapNode[K, V]{}
+	}
+
+	if shift >= 64 {
+		for i, s := range n.slots {
+			if s.key == e.key {
+				return n.replace(i, e), false
+			}
+		}
+		return n.insert(len(n.slots), 0, e), true
+	}
+
+	bit := uint32(1) << ((e.hash >> shift) & pmapMask)
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		return n.insert(i, bit, e), true
+	}
+
+	s := n.slots[i]
+	switch {
+	case s.child != nil:
+		child, added := s.child.set(shift+pmapBits, e)
+		return n.replace(i, pmapSlot[K, V]{child: child}), added
+	case s.key == e.key:
+		return n.replace(i, e), false
+	default:
+		// Both entries move one level down, where their hashes may differ
+		child, _ := (*pmapNode[K, V])(nil).set(shift+pmapBits, s)
+		child, _ = child.set(shift+pmapBits, e)
+		return n.replace(i, pmapSlot[K, V]{child: child}), true
+	}
+}
+
+// delete returns a copy of the node without the key, nil once empty, and whether the key was found
+func (n *pmapNode[K, V]) delete(shift uint, hash uint64, key K) (*pmapNode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if shift >= 64 {
+		for i, s := range n.slots {
+			if s.key == key {
+				return n.remove(i, 0), true
+			}
+		}
+		return n, false
+	}
+
+	bit := uint32(1) << ((hash >> shift) & pmapMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+	s := n.slots[i]
+	if s.child == nil {
+		if s.key != key {
+			return n, false
+		}
+		return n.remove(i, bit), true
+	}
+
+	child, removed := s.child.delete(shift+pmapBits, hash, key)
+	switch {
+	case !removed:
+		return n, false
+	case child == nil:
+		return n.remove(i, bit), true
+	case len(child.slots) == 1 && child.slots[0].child == nil:
+		// A child left with a single entry is folded back into its parent
+		return n.replace(i, child.slots[0]), true
+	default:
+		return n.replace(i, pmapSlot[K, V]{child: child}), true
+	}
+}
+
+func (n *pmapNode[K, V]) each(fn func(K, V)) {
+	if n == nil {
+		return
+	}
+
+	for _, s := range n.slots {
+		if s.child != nil {
+			s.child.each(fn)
+		} else {
+			fn(s.key, s.value)
+		}
+	}
+}
+
+// replace returns a copy of the node with the slot i replaced by s
+func (n *pmapNode[K, V]) replace(i int, s pmapSlot[K, V]) *pmapNode[K, V] {
+	c := &pmapNode[K, V]{bitmap: n.bitmap, slots: make([]pmapSlot[K, V], len(n.slots))}
+	copy(c.slots, n.slots)
+	c.slots[i] = s
+
+	return c
+}
+
+// insert returns a copy of the node with s inserted at i and the bit set
+func (n *pmapNode[K, V]) insert(i int, bit uint32, s pmapSlot[K, V]) *pmapNode[K, V] {
+	c := &pmapNode[K, V]{bitmap: n.bitmap | bit, slots: make([]pmapSlot[K, V], len(n.slots)+1)}
+	copy(c.slots, n.slots[:i])
+	c.slots[i] = s
+	copy(c.slots[i+1:], n.slots[i:])
+
+	return c
+}
+
+// remove returns a copy of the node without the slot i and the bit, nil if it was the last slot
+func (n *pmapNode[K, V]) remove(i int, bit uint32) *pmapNode[K, V] {
+	if len(n.slots) == 1 {
+		return nil
+	}
+
+	c := &pmapNode[K, V]{bitmap: n.bitmap &^ bit, slots: make([]pmapSlot[K, V], 0, len(n.slots)-1)}
+	c.slots = append(c.slots, n.slots[:i]...)
+	c.slots = append(c.slots, n.slots[i+1:]...)
+
+	return c
+}
+
+// hashKey hashes the keys of a pmap
+func hashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		// FNV-1a
+		h := uint64(14695981039346656037)
+		for i := 0; i < len(k); i++ {
+			h ^= uint64(k[i])
+			h *= 1099511628211
+		}
+		return h
+	case uint64:
+		// The finalizer of splitmix64 spreads the sequential ids over every level
+		k ^= k >> 30
+		k *= 0xbf58476d1ce4e5b9
+		k ^= k >> 27
+		k *= 0x94d049bb133111eb
+		k ^= k >> 31
+		return k
+	default:
+		panic("mq: pmap keys are strings or uint64")
+	}
+}
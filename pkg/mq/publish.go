@@ -0,0 +1,125 @@
+package mq
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+var (
+	// ErrBrokerClosed is returned by the operations of a draining or closed broker
+	ErrBrokerClosed = errors.New("mq: broker closed")
+
+	// ErrNoSubscribers is returned by a mandatory publish when no subscription matches the topic
+	ErrNoSubscribers = errors.New("mq: no subscribers")
+)
+
+// PublishResult reports what the subscriptions matching the topic did with a published message
+type PublishResult struct {
+	// Matched is the number of subscriptions matching the topic, a consumer group counts once
+	Matched int
+
+	// Accepted is the number of subscriptions which queued the message
+	Accepted int
+
+	// Dropped is the number of subscriptions which discarded the message, because they were full or closed meanwhile
+	Dropped int
+}
+
+// PublishOption configures a publish made with Broker.PublishE
+type PublishOption func(*publishOptions)
+
+// publishOptions holds the configuration of a publish
+type publishOptions struct {
+	mandatory bool
+}
+
+// Mandatory makes the publish fail with ErrNoSubscribers when no subscription matches the topic
+func Mandatory() PublishOption {
+	return func(o *publishOptions) {
+		o.mandatory = true
+	}
+}
+
+func (b *broker[T]) PublishE(topic string, data T, opts ...PublishOption) (PublishResult, error) {
+	return b.PublishMessageE(Message[T]{Topic: topic, Payload: data}, opts...)
+}
+
+func (b *broker[T]) PublishMessageE(msg Message[T], opts ...PublishOption) (PublishResult, error) {
+	o := publishOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if b.State() != Running {
+		return PublishResult{}, ErrBrokerClosed
+	}
+
+	result := b.publish(msg)
+	if o.mandatory && result.Matched == 0 {
+		return result, ErrNoSubscribers
+	}
+
+	return result, nil
+}
+
+// publish pushes the message to every subscription matching its topic
+func (b *broker[T]) publish(msg Message[T]) PublishResult {
+	msg = b.stamp(msg)
+	env := envelope[T]{Message: msg}
+	var dropped bool
+	// The room left is taken by the deadline of the acknowledged subscriptions
+	opts := make([]queue.PushOption, 0, 4)
+	opts = append(opts, queue.Priority(msg.Priority), queue.Dropped(&dropped))
+	if msg.TTL > 0 {
+		opts = append(opts, queue.TTL(msg.TTL))
+	}
+
+	matched := b.match(msg.Topic)
+	result := PublishResult{Matched: len(matched)}
+	for _, qm := range matched {
+		dropped = false
+		env.Headers = copyHeaders(msg.Headers)
+		pushOpts := opts
+		env.deadline = nil
+		if qm.ack != nil {
+			env.deadline = new(time.Time)
+			pushOpts = append(opts, queue.Deadline(env.deadline))
+		}
+
+		// A subscription closed meanwhile returns queue.ErrClosed
+		if err := qm.queue.Push(env, pushOpts...); err != nil || dropped {
+			result.Dropped++
+		} else {
+			result.Accepted++
+		}
+	}
+
+	return result
+}
+
+// copyHeaders returns a copy of the headers, so that each subscription can change its own
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(headers))
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
+
+// envelope wraps the message for the subscription along with the options to push it with
+// An acknowledged subscription records the deadline of the message once stored, so that its redeliveries keep it
+func (qm *queueMatcher[T]) envelope(msg Message[T]) (envelope[T], []queue.PushOption) {
+	env := envelope[T]{Message: msg}
+	if qm.ack == nil {
+		return env, nil
+	}
+
+	env.deadline = new(time.Time)
+	return env, []queue.PushOption{queue.Deadline(env.deadline)}
+}
@@ -0,0 +1,46 @@
+package mq
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+func TestPublishE(t *testing.T) {
+	broker := NewBroker[int]()
+
+	broker.Subscribe(ExactMatcher("test"))
+	broker.Subscribe(ExactMatcher("test"), queue.WithCapacity(1), queue.WithOverflowPolicy(queue.DropNewest))
+	broker.Subscribe(ExactMatcher("test"), queue.WithCapacity(1), queue.WithOverflowPolicy(queue.Reject))
+	broker.SubscribeGroup("workers", ExactMatcher("test"))
+	broker.SubscribeGroup("workers", ExactMatcher("test"))
+
+	result, err := broker.PublishE("test", 1)
+	if err != nil || result != (PublishResult{Matched: 4, Accepted: 4}) {
+		t.Errorf("Invalid Result: Expected: {4 4 0} <nil> Obtained: %+v %v", result, err)
+	}
+
+	// Both bounded subscriptions are full
+	result, err = broker.PublishE("test", 2)
+	if err != nil || result != (PublishResult{Matched: 4, Accepted: 2, Dropped: 2}) {
+		t.Errorf("Invalid Result: Expected: {4 2 2} <nil> Obtained: %+v %v", result, err)
+	}
+
+	result, err = broker.PublishE("other", 3)
+	if err != nil || result != (PublishResult{}) {
+		t.Errorf("Invalid Result: Expected: {0 0 0} <nil> Obtained: %+v %v", result, err)
+	}
+
+	if _, err = broker.PublishE("other", 3, Mandatory()); !errors.Is(err, ErrNoSubscribers) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrNoSubscribers, err)
+	}
+	if _, err = broker.PublishE("test", 3, Mandatory()); err != nil {
+		t.Errorf("Invalid Error: Expected: <nil> Obtained: %v", err)
+	}
+
+	broker.Close(0)
+	if _, err = broker.PublishE("test", 4); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Invalid Error: Expected: %v Obtained: %v", ErrBrokerClosed, err)
+	}
+}
@@ -0,0 +1,47 @@
+package mq
+
+import "github.com/Dev-Destructor/go-queue/pkg/queue"
+
+// SubscriptionStats is a snapshot of the counters of a single subscription
+type SubscriptionStats struct {
+	ID      uint64
+	Matcher Matcher
+
+	// Group is the name of the consumer group, empty for a plain subscription
+	Group string
+
+	// Members is the number of subscriptions sharing the queue
+	Members int
+
+	queue.Stats
+}
+
+// Stats is a snapshot of the counters of every subscription of a broker
+type Stats struct {
+	Subscriptions []SubscriptionStats
+
+	// Enqueued, Dequeued, Dropped, Expired and Depth are the sums over all the subscriptions
+	Enqueued uint64
+	Dequeued uint64
+	Dropped  uint64
+	Expired  uint64
+	Depth    int
+
+	// MaxDepth is the depth of the most backlogged subscription
+	MaxDepth int
+
+	// MatchCache holds the counters of the cache of the subscriptions matching each topic
+	MatchCache MatchCacheStats
+}
+
+func (s *Stats) add(sub SubscriptionStats) {
+	s.Subscriptions = append(s.Subscriptions, sub)
+	s.Enqueued += sub.Enqueued
+	s.Dequeued += sub.Dequeued
+	s.Dropped += sub.Dropped
+	s.Expired += sub.Expired
+	s.Depth += sub.Depth
+	if sub.Depth > s.MaxDepth {
+		s.MaxDepth = sub.Depth
+	}
+}
@@ -0,0 +1,124 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Dev-Destructor/go-queue/pkg/queue"
+)
+
+// Subscription is a Poller returned by Broker.Subscribe which can be removed on its own
+type Subscription[T any] interface {
+	Poller[T]
+
+	// PollMessage works like Poll but returns the whole message, including its topic and headers.
+	PollMessage() (Message[T], bool)
+
+	// ID returns the identifier of the subscription, unique within its broker.
+	ID() uint64
+
+	// OnExpiry calls fn with every message discarded because its TTL elapsed, see queue.Queue.OnExpiry.
+	// The members of a group share the handler of their queue, the last one set applies.
+	OnExpiry(fn func(Message[T]))
+
+	// Unsubscribe removes the subscription from the broker and closes it.
+	// A group member only leaves the group, the queue of the group is closed once its last member left.
+	// If the timeOut is less than 0, then the subscription will be read-only.
+	Unsubscribe(timeOut time.Duration)
+}
+
+type subscription[T any] struct {
+	queue  queue.Queue[envelope[T]]
+	id     uint64
+	broker *broker[T]
+	once   sync.Once
+
+	// queueID is the id of the queue, shared by the members of a group
+	queueID uint64
+
+	// channel is returned by Chan, it is created along with its forwarding goroutine by the first call
+	channel     <-chan T
+	channelOnce sync.Once
+}
+
+func (s *subscription[T]) Poll() (T, bool) {
+	msg, ok := s.queue.Poll()
+	return msg.Payload, ok
+}
+
+func (s *subscription[T]) PollMessage() (Message[T], bool) {
+	env, ok := s.queue.Poll()
+	return env.Message, ok
+}
+
+func (s *subscription[T]) PollContext(ctx context.Context) (T, error) {
+	msg, err := s.queue.PollContext(ctx)
+	return msg.Payload, err
+}
+
+func (s *subscription[T]) TryPoll() (T, bool, bool) {
+	msg, ok, available := s.queue.TryPoll()
+	return msg.Payload, ok, available
+}
+
+func (s *subscription[T]) PollTimeout(d time.Duration) (T, bool, bool) {
+	msg, ok, available := s.queue.PollTimeout(d)
+	return msg.Payload, ok, available
+}
+
+func (s *subscription[T]) PollBatch(max int, wait time.Duration) []T {
+	envs := s.queue.PollBatch(max, wait)
+	if len(envs) == 0 {
+		return nil
+	}
+
+	batch := make([]T, len(envs))
+	for i, env := range envs {
+		batch[i] = env.Payload
+	}
+	return batch
+}
+
+func (s *subscription[T]) Chan() <-chan T {
+	s.channelOnce.Do(func() {
+		s.channel = queue.CFunc(s.queue, func(env envelope[T]) T {
+			return env.Payload
+		})
+	})
+
+	return s.channel
+}
+
+func (s *subscription[T]) Len() int {
+	return s.queue.Len()
+}
+
+func (s *subscription[T]) IsClosed() bool {
+	return s.queue.IsClosed()
+}
+
+func (s *subscription[T]) Stats() queue.Stats {
+	return s.queue.Stats()
+}
+
+func (s *subscription[T]) ID() uint64 {
+	return s.id
+}
+
+func (s *subscription[T]) OnExpiry(fn func(Message[T])) {
+	if fn == nil {
+		s.queue.OnExpiry(nil)
+		return
+	}
+
+	s.queue.OnExpiry(func(env envelope[T]) {
+		fn(env.Message)
+	})
+}
+
+func (s *subscription[T]) Unsubscribe(timeOut time.Duration) {
+	s.once.Do(func() {
+		s.broker.leave(s.queueID, timeOut)
+	})
+}
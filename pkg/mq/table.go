@@ -0,0 +1,81 @@
+package mq
+
+import "sort"
+
+// table is an immutable snapshot of the subscriptions of a broker
+// Publishers load the current table without locking, subscribing and unsubscribing replace it with a modified copy.
+// The copies share the index and byID but for the path to the changed subscriptions, so that subscribing costs O(log n).
+type table[T any] struct {
+	// queueMatchers are ordered by id, i.e. in the order the subscriptions were created
+	// A copy made by with appends to the array of the table it was made from, which no other copy is made from,
+	// the readers of that table never look past its length
+	queueMatchers []*queueMatcher[T]
+	byID          pmap[uint64, *queueMatcher[T]]
+	index         *index
+
+	// generation tells the tables apart, the match cache only holds the matches of the current one
+	generation uint64
+}
+
+func newTable[T any](generation uint64) *table[T] {
+	return &table[T]{
+		index:      newIndex(),
+		generation: generation,
+	}
+}
+
+// with returns a copy of the table with the subscription added, it must be the current table of the broker
+func (t *table[T]) with(qm *queueMatcher[T]) *table[T] {
+	return &table[T]{
+		queueMatchers: append(t.queueMatchers, qm),
+		byID:          t.byID.set(qm.id, qm),
+		index:         t.index.with(qm.id, qm.matcher),
+		generation:    t.generation + 1,
+	}
+}
+
+// without returns a copy of the table without the subscriptions for which drop returns true, along with them
+// It returns the table itself if no subscription is dropped
+func (t *table[T]) without(drop func(*queueMatcher[T]) bool) (*table[T], []*queueMatcher[T]) {
+	var dropped []*queueMatcher[T]
+	kept := make([]*queueMatcher[T], 0, len(t.queueMatchers))
+	for _, qm := range t.queueMatchers {
+		if drop(qm) {
+			dropped = append(dropped, qm)
+		} else {
+			kept = append(kept, qm)
+		}
+	}
+
+	if len(dropped) == 0 {
+		return t, nil
+	}
+
+	next := &table[T]{
+		queueMatchers: kept,
+		byID:          t.byID,
+		index:         t.index,
+		generation:    t.generation + 1,
+	}
+	for _, qm := range dropped {
+		next.byID = next.byID.delete(qm.id)
+		next.index = next.index.without(qm.id, qm.matcher)
+	}
+
+	return next, dropped
+}
+
+// lookup finds the subscriptions matching the topic through the index
+func (t *table[T]) lookup(topic string) []*queueMatcher[T] {
+	ids := t.index.match(topic)
+	matched := make([]*queueMatcher[T], 0, len(ids))
+	for id := range ids {
+		qm, _ := t.byID.get(id)
+		matched = append(matched, qm)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].id < matched[j].id
+	})
+
+	return matched
+}
@@ -0,0 +1,103 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Separator and wildcards of the TopicMatcher patterns
+const (
+	// TopicSeparator separates the levels of a topic
+	TopicSeparator = "."
+
+	// SingleLevelWildcard matches exactly one level, even an empty one
+	SingleLevelWildcard = "*"
+
+	// MultiLevelWildcard matches zero or more trailing levels, like # in MQTT and AMQP
+	MultiLevelWildcard = "#"
+
+	// TailWildcard matches one or more trailing levels, like > in NATS
+	TailWildcard = ">"
+)
+
+// ErrInvalidPattern is returned when a TopicMatcher pattern is malformed
+var ErrInvalidPattern = errors.New("mq: invalid topic pattern")
+
+// TopicMatcher matches hierarchical topics whose levels are separated by dots
+// The pattern "orders.*.created" matches "orders.eu.created", "orders.#" matches "orders" and "orders.eu.created"
+// while "orders.>" matches "orders.eu.created" but not "orders".
+// Two TopicMatchers with the same pattern are equal, so CloseTopic closes the subscriptions of both.
+type TopicMatcher struct {
+	pattern string
+}
+
+// NewTopicMatcher validates the pattern and returns its TopicMatcher
+// Every level must be non empty, a wildcard must fill its whole level and the multi-level wildcards must be the last level.
+func NewTopicMatcher(pattern string) (TopicMatcher, error) {
+	if pattern == "" {
+		return TopicMatcher{}, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+
+	levels := strings.Split(pattern, TopicSeparator)
+	for i, level := range levels {
+		switch {
+		case level == "":
+			return TopicMatcher{}, fmt.Errorf("%w: %q has an empty level", ErrInvalidPattern, pattern)
+		case level == SingleLevelWildcard:
+		case level == MultiLevelWildcard || level == TailWildcard:
+			if i != len(levels)-1 {
+				return TopicMatcher{}, fmt.Errorf("%w: %q has %s before its last level", ErrInvalidPattern, pattern, level)
+			}
+		case strings.ContainsAny(level, SingleLevelWildcard+MultiLevelWildcard+TailWildcard):
+			return TopicMatcher{}, fmt.Errorf("%w: %q mixes a wildcard with other characters in %q", ErrInvalidPattern, pattern, level)
+		}
+	}
+
+	return TopicMatcher{pattern: pattern}, nil
+}
+
+// MustTopicMatcher is like NewTopicMatcher but panics if the pattern is invalid
+func MustTopicMatcher(pattern string) TopicMatcher {
+	tm, err := NewTopicMatcher(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return tm
+}
+
+// MatchString returns true if the topic matches the pattern
+func (tm TopicMatcher) MatchString(topic string) bool {
+	pattern := tm.pattern
+	if pattern == "" {
+		return false
+	}
+
+	// Walk both strings level by level without allocating, a topic has at least one level, possibly empty
+	for {
+		level, restPattern, morePattern := strings.Cut(pattern, TopicSeparator)
+		if level == MultiLevelWildcard || level == TailWildcard {
+			return true
+		}
+
+		name, restTopic, moreTopic := strings.Cut(topic, TopicSeparator)
+		if level != SingleLevelWildcard && level != name {
+			return false
+		}
+
+		if !morePattern {
+			return !moreTopic
+		}
+		if !moreTopic {
+			// Only a trailing # matches the absence of levels
+			return restPattern == MultiLevelWildcard
+		}
+		pattern, topic = restPattern, restTopic
+	}
+}
+
+// String returns the pattern of the matcher
+func (tm TopicMatcher) String() string {
+	return tm.pattern
+}
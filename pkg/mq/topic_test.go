@@ -0,0 +1,85 @@
+package mq
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTopicMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		match   bool
+	}{
+		{pattern: "orders", topic: "orders", match: true},
+		{pattern: "orders", topic: "orders.created", match: false},
+		{pattern: "orders.*.created", topic: "orders.eu.created", match: true},
+		{pattern: "orders.*.created", topic: "orders.created", match: false},
+		{pattern: "orders.*.created", topic: "orders.eu.west.created", match: false},
+		{pattern: "orders.*", topic: "orders.", match: true},
+		{pattern: "orders.*", topic: "orders..", match: false},
+		{pattern: "orders.#", topic: "orders.", match: true},
+		{pattern: "*.created", topic: ".created", match: true},
+		{pattern: "*", topic: "orders", match: true},
+		{pattern: "*", topic: "orders.created", match: false},
+		{pattern: "orders.#", topic: "orders", match: true},
+		{pattern: "orders.#", topic: "orders.eu.created", match: true},
+		{pattern: "orders.#", topic: "ordersx", match: false},
+		{pattern: "orders.>", topic: "orders", match: false},
+		{pattern: "orders.>", topic: "orders.eu", match: true},
+		{pattern: "orders.>", topic: "orders.eu.created", match: true},
+		{pattern: "*.>", topic: "orders", match: false},
+		{pattern: "#", topic: "orders.eu.created", match: true},
+	}
+
+	for _, test := range tests {
+		if match := MustTopicMatcher(test.pattern).MatchString(test.topic); match != test.match {
+			t.Errorf("Invalid Match of %q with %q: Expected: %v, Obtained: %v", test.topic, test.pattern, test.match, match)
+		}
+	}
+}
+
+func TestTopicMatcherValidation(t *testing.T) {
+	for _, pattern := range []string{"", "orders..created", ".orders", "orders.", "orders.#.created", "orders.>.created", "ord*", "orders.#x"} {
+		if _, err := NewTopicMatcher(pattern); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("Invalid Error for %q: Expected: %v, Obtained: %v", pattern, ErrInvalidPattern, err)
+		}
+	}
+
+	if tm, err := NewTopicMatcher("orders.*.created"); err != nil || tm.String() != "orders.*.created" {
+		t.Errorf("Invalid Matcher: %v %v", tm, err)
+	}
+	if (TopicMatcher{}).MatchString("") {
+		t.Error("The zero TopicMatcher should not match")
+	}
+}
+
+func TestBrokerTopicMatcher(t *testing.T) {
+	broker := NewBroker[int]()
+	defer broker.Close(0)
+
+	created := broker.Subscribe(MustTopicMatcher("orders.*.created"))
+	all := broker.Subscribe(MustTopicMatcher("orders.#"))
+	other := broker.Subscribe(MustTopicMatcher("orders.#"))
+
+	broker.Publish("orders.eu.created", 1)
+	broker.Publish("orders.eu.deleted", 2)
+
+	if val, _, available := created.TryPoll(); !available || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v", val)
+	}
+	if _, _, available := created.TryPoll(); available {
+		t.Error("orders.eu.deleted should not match orders.*.created")
+	}
+	for _, expected := range []int{1, 2} {
+		if val, _, available := all.TryPoll(); !available || val != expected {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v", expected, val)
+		}
+	}
+
+	// Matchers with the same pattern are equal
+	broker.CloseTopic(MustTopicMatcher("orders.#"), -1)
+	if !all.IsClosed() || !other.IsClosed() || created.IsClosed() {
+		t.Error("CloseTopic should close every subscription with the same pattern")
+	}
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+func (q *queue[T]) PushBatch(values []T, opts ...PushOption) error {
+	return q.PushBatchContext(context.Background(), values, opts...)
+}
+
+func (q *queue[T]) PushBatchContext(ctx context.Context, values []T, opts ...PushOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	entries := make([]entry[T], len(values))
+	var o pushOptions
+	for i, value := range values {
+		entries[i], o = q.entry(value, opts)
+	}
+
+	// Hand over as many entries as the queue accepts at once
+	pushed := 0
+	err := ErrClosed
+	q.do(func() {
+		err = nil
+		for _, e := range entries {
+			if q.IsClosed() {
+				err = ErrClosed
+				return
+			}
+			if q.full() && q.options.overflow == Block {
+				return
+			}
+
+			if err = q.admit(e); err == errDropped {
+				if o.dropped != nil {
+					*o.dropped = true
+				}
+				err = nil
+			} else if err != nil {
+				return
+			}
+			pushed++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// A full queue blocking its producers takes the remaining entries one by one
+	for _, e := range entries[pushed:] {
+		if err := q.send(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *queue[T]) PollBatch(max int, wait time.Duration) []T {
+	if max <= 0 {
+		return nil
+	}
+
+	first, _, available := q.PollTimeout(wait)
+	if !available {
+		return nil
+	}
+
+	batch := make([]T, 1, max)
+	batch[0] = first
+	for len(batch) < max {
+		entries := q.take(max - len(batch))
+		if len(entries) == 0 {
+			break
+		}
+
+		taken := len(batch)
+		for _, e := range entries {
+			if !q.expired(e) {
+				batch = append(batch, e.value)
+			}
+		}
+		q.counters.polled(len(batch) - taken)
+		q.settle()
+	}
+
+	return batch
+}
+
+// take removes up to n entries from the queue at once without blocking nor checking their expiry
+func (q *queue[T]) take(n int) []entry[T] {
+	var entries []entry[T]
+	running := q.do(func() {
+		// Entries already handed to dequeue are older than the ones in items
+		select {
+		case e := <-q.dequeue:
+			entries = append(entries, e)
+		default:
+		}
+
+		for len(entries) < n && q.items.len() > 0 {
+			entries = append(entries, q.items.pop())
+		}
+	})
+	if !running {
+		// The manage goroutine exited, the closed dequeue holds at most its buffered entry
+		if e, ok := <-q.dequeue; ok {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// entry is a value held by a queue along with what decides its order
+type entry[T any] struct {
+	value    T
+	priority int
+
+	// seq orders the entries of equal priority by arrival
+	seq uint64
+
+	// ttl is how long the entry lives once stored, and expires the resulting deadline
+	ttl     time.Duration
+	expires time.Time
+
+	// deadline shares expires with the pusher, see Deadline
+	deadline *time.Time
+}
+
+// buffer holds the entries of a queue in the order they are polled
+type buffer[T any] interface {
+	// push adds the entry to the buffer
+	push(e entry[T])
+
+	// peek returns the entry to be polled next, the buffer must not be empty
+	peek() entry[T]
+
+	// pop removes and returns the entry to be polled next, the buffer must not be empty
+	pop() entry[T]
+
+	// unpop puts back an entry returned by pop, it is polled next unless a more urgent entry arrived since
+	unpop(e entry[T])
+
+	// evict discards the oldest entry of the lowest priority, the buffer must not be empty
+	evict()
+
+	// len returns the number of entries in the buffer
+	len() int
+
+	// purge removes the entries expired at now, passing each to expired,
+	// and returns the earliest expiry of the entries left, zero if none expires
+	purge(now time.Time, expired func(entry[T])) time.Time
+}
+
+// fifo is a buffer polling the entries in arrival order
+type fifo[T any] struct {
+	entries []entry[T]
+}
+
+func (f *fifo[T]) push(e entry[T]) {
+	f.entries = append(f.entries, e)
+}
+
+func (f *fifo[T]) peek() entry[T] {
+	return f.entries[0]
+}
+
+func (f *fifo[T]) pop() entry[T] {
+	e := f.entries[0]
+	f.entries[0] = entry[T]{}
+	f.entries = f.entries[1:]
+	return e
+}
+
+func (f *fifo[T]) unpop(e entry[T]) {
+	f.entries = append([]entry[T]{e}, f.entries...)
+}
+
+func (f *fifo[T]) evict() {
+	f.pop()
+}
+
+func (f *fifo[T]) len() int {
+	return len(f.entries)
+}
+
+func (f *fifo[T]) purge(now time.Time, expired func(entry[T])) time.Time {
+	f.entries = purgeEntries(f.entries, now, expired)
+	return earliestExpiry(f.entries)
+}
+
+// priorityHeap is a buffer polling the entries of higher priority first, and in arrival order among equal priorities
+type priorityHeap[T any] struct {
+	entries entryHeap[T]
+}
+
+func (p *priorityHeap[T]) push(e entry[T]) {
+	heap.Push(&p.entries, e)
+}
+
+func (p *priorityHeap[T]) peek() entry[T] {
+	return p.entries[0]
+}
+
+func (p *priorityHeap[T]) pop() entry[T] {
+	return heap.Pop(&p.entries).(entry[T])
+}
+
+func (p *priorityHeap[T]) unpop(e entry[T]) {
+	p.push(e)
+}
+
+func (p *priorityHeap[T]) evict() {
+	victim := 0
+	for i := 1; i < len(p.entries); i++ {
+		e, v := p.entries[i], p.entries[victim]
+		if e.priority < v.priority || (e.priority == v.priority && e.seq < v.seq) {
+			victim = i
+		}
+	}
+
+	heap.Remove(&p.entries, victim)
+}
+
+func (p *priorityHeap[T]) len() int {
+	return len(p.entries)
+}
+
+func (p *priorityHeap[T]) purge(now time.Time, expired func(entry[T])) time.Time {
+	n := len(p.entries)
+	p.entries = purgeEntries(p.entries, now, expired)
+	if len(p.entries) != n {
+		heap.Init(&p.entries)
+	}
+	return earliestExpiry(p.entries)
+}
+
+// purgeEntries removes the entries expired at now in place, keeping the order of the others
+func purgeEntries[T any](entries []entry[T], now time.Time, expired func(entry[T])) []entry[T] {
+	kept := entries[:0]
+	for _, e := range entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			expired(e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	// Clear the tail so that the purged values can be collected
+	for i := len(kept); i < len(entries); i++ {
+		entries[i] = entry[T]{}
+	}
+	return kept
+}
+
+// earliestExpiry returns the earliest expiry of the entries, zero if none expires
+func earliestExpiry[T any](entries []entry[T]) time.Time {
+	var earliest time.Time
+	for _, e := range entries {
+		if !e.expires.IsZero() && (earliest.IsZero() || e.expires.Before(earliest)) {
+			earliest = e.expires
+		}
+	}
+	return earliest
+}
+
+// entryHeap implements heap.Interface over entries
+type entryHeap[T any] []entry[T]
+
+func (h entryHeap[T]) Len() int {
+	return len(h)
+}
+
+func (h entryHeap[T]) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h entryHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *entryHeap[T]) Push(x interface{}) {
+	*h = append(*h, x.(entry[T]))
+}
+
+func (h *entryHeap[T]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = entry[T]{}
+	*h = old[:n-1]
+	return e
+}
+
+// scheduled is an entry waiting for its due time before being added to the buffer
+type scheduled[T any] struct {
+	entry[T]
+	due time.Time
+}
+
+// schedule holds the scheduled entries ordered by due time, and by arrival among equal due times
+type schedule[T any] []scheduled[T]
+
+func (s schedule[T]) Len() int {
+	return len(s)
+}
+
+func (s schedule[T]) Less(i, j int) bool {
+	if !s[i].due.Equal(s[j].due) {
+		return s[i].due.Before(s[j].due)
+	}
+	return s[i].seq < s[j].seq
+}
+
+func (s schedule[T]) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s *schedule[T]) Push(x interface{}) {
+	*s = append(*s, x.(scheduled[T]))
+}
+
+func (s *schedule[T]) Pop() interface{} {
+	old := *s
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = scheduled[T]{}
+	*s = old[:n-1]
+	return e
+}
@@ -0,0 +1,102 @@
+package queue
+
+// forwarder is a goroutine handing the values of a queue to a channel
+type forwarder[T any] struct {
+	// done is closed once the goroutine exited, held is the entry it still held when Shutdown stopped it
+	done chan struct{}
+	held []entry[T]
+}
+
+// CFunc returns a channel receiving the values polled from q converted by convert, see Queue.C
+// Every call starts a goroutine of its own, which exits once the queue is closed and drained or force closed.
+// q must be created by New or NewPriority, CFunc panics otherwise
+func CFunc[T, U any](q Queue[T], convert func(T) U) <-chan U {
+	qq, ok := q.(*queue[T])
+	if !ok {
+		panic("queue: CFunc takes a queue created by New")
+	}
+
+	qq.forwardMu.Lock()
+	defer qq.forwardMu.Unlock()
+
+	return forward(qq, convert)
+}
+
+func (q *queue[T]) C() <-chan T {
+	q.forwardMu.Lock()
+	defer q.forwardMu.Unlock()
+
+	if q.channel == nil {
+		q.channel = forward(q, func(value T) T {
+			return value
+		})
+	}
+
+	return q.channel
+}
+
+// forward starts a goroutine handing the values of the queue converted by convert to the returned channel,
+// the caller must hold forwardMu
+// A value only counts as polled once it is received. The goroutine exits once the queue is closed and drained,
+// dropping the value it holds on a forced close and handing it back to a Shutdown.
+func forward[T, U any](q *queue[T], convert func(T) U) <-chan U {
+	out := make(chan U)
+	f := &forwarder[T]{done: make(chan struct{})}
+	q.forwarders = append(q.forwarders, f)
+
+	go func() {
+		defer close(f.done)
+		defer close(out)
+
+		for {
+			var e entry[T]
+			select {
+			case next, ok := <-q.dequeue:
+				if !ok {
+					return
+				}
+				e = next
+			case <-q.stopping:
+				return
+			}
+
+			if q.expired(e) {
+				continue
+			}
+
+			select {
+			case out <- convert(e.value):
+				q.counters.polled(1)
+				q.settle()
+			case <-q.stopping:
+				f.held = append(f.held, e)
+				return
+			case <-q.close:
+				q.counters.drop(1)
+				q.settle()
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// stopForwarding stops the forwarding goroutines and returns the entries they held
+func (q *queue[T]) stopForwarding() []entry[T] {
+	q.stopOnce.Do(func() {
+		close(q.stopping)
+	})
+
+	q.forwardMu.Lock()
+	forwarders := q.forwarders
+	q.forwardMu.Unlock()
+
+	var held []entry[T]
+	for _, f := range forwarders {
+		<-f.done
+		held = append(held, f.held...)
+	}
+
+	return held
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+)
+
+func (q *queue[T]) Drain(ctx context.Context) error {
+	q.Close(-1)
+
+	// The manage goroutine exits once it handed out every value, the last ones may still wait in the dequeue buffer
+	select {
+	case <-q.emptied:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *queue[T]) Shutdown(ctx context.Context) ([]T, error) {
+	err := q.Drain(ctx)
+	if err == nil {
+		return nil, nil
+	}
+
+	// The entries held by the forwarding goroutines are the oldest ones
+	entries := q.stopForwarding()
+
+	var delayed []entry[T]
+	running := q.do(func() {
+		// Entries already handed to dequeue are older than the ones in items
+	buffered:
+		for {
+			select {
+			case e := <-q.dequeue:
+				entries = append(entries, e)
+			default:
+				break buffered
+			}
+		}
+
+		for q.items.len() > 0 {
+			entries = append(entries, q.items.pop())
+		}
+		for len(q.delayed) > 0 {
+			delayed = append(delayed, heap.Pop(&q.delayed).(scheduled[T]).entry)
+		}
+		q.counters.unschedule(len(delayed))
+	})
+	if running {
+		q.forceClose()
+	}
+
+	// The entries left in the dequeue buffer once the manage goroutine exited are handed back as well
+	for e := range q.dequeue {
+		entries = append(entries, e)
+	}
+
+	var leftovers []T
+	for _, e := range entries {
+		if !q.expired(e) {
+			leftovers = append(leftovers, e.value)
+		}
+	}
+	// The values handed back count as polled, so that the queue ends up empty
+	q.counters.polled(len(leftovers))
+	q.settle()
+
+	for _, e := range delayed {
+		leftovers = append(leftovers, e.value)
+	}
+
+	return leftovers, err
+}
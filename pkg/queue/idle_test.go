@@ -0,0 +1,50 @@
+//go:build unix
+
+package queue
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+// cpuTime returns the CPU time used by the process so far
+func cpuTime(t *testing.T) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		t.Fatalf("Getrusage: %v\n", err)
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+func TestIdleReadOnlyQueueUsesNoCPU(t *testing.T) {
+	queues := []Queue[int]{
+		New[int](),
+		New[int](WithCapacity(10)),
+		NewPriority[int](),
+	}
+	for _, queue := range queues {
+		for i := 0; i < 10; i++ {
+			queue.Push(i)
+		}
+		queue.PushAfter(10, time.Hour)
+		queue.Close(-1)
+	}
+
+	idle := 200 * time.Millisecond
+	before := cpuTime(t)
+	time.Sleep(idle)
+	used := cpuTime(t) - before
+
+	// A spinning manager would burn the whole idle time
+	if used > idle/4 {
+		t.Errorf("Idle read-only queues used %v of CPU in %v\n", used, idle)
+	}
+
+	for _, queue := range queues {
+		if values := pollAll(queue); len(values) != 10 {
+			t.Errorf("Invalid Values: Expected: 10 values, Obtained: %v\n", values)
+		}
+	}
+}
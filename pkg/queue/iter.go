@@ -0,0 +1,18 @@
+//go:build go1.23
+
+package queue
+
+import "iter"
+
+// All returns an iterator polling the values of q until it is closed and drained
+// Breaking out of the loop stops polling, the values after the one it broke on stay in the queue
+func All[T any](q interface{ Poll() (T, bool) }) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			value, ok := q.Poll()
+			if !ok || !yield(value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.23
+
+package queue
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	queue := New[int]()
+	for i := 0; i < 5; i++ {
+		queue.Push(i)
+	}
+	queue.Close(-1)
+
+	var values []int
+	for val := range All(queue) {
+		if val == 3 {
+			break
+		}
+		values = append(values, val)
+	}
+	if fmt.Sprint(values) != "[0 1 2]" {
+		t.Errorf("Invalid Values: Expected: [0 1 2], Obtained: %v\n", values)
+	}
+
+	for val := range All(queue) {
+		values = append(values, val)
+	}
+	if fmt.Sprint(values) != "[0 1 2 4]" {
+		t.Errorf("Invalid Values: Expected: [0 1 2 4], Obtained: %v\n", values)
+	}
+}
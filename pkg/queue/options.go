@@ -0,0 +1,138 @@
+package queue
+
+import "time"
+
+// OverflowPolicy decides what a bounded queue does with a value pushed while it is full
+type OverflowPolicy int
+
+const (
+	// Block blocks the producer until there is room in the queue
+	Block OverflowPolicy = iota
+
+	// DropNewest silently discards the value being pushed
+	DropNewest
+
+	// DropOldest discards the oldest value in the queue to make room for the new one
+	DropOldest
+
+	// Reject makes Push return ErrFull
+	Reject
+)
+
+// Option configures a queue created by New
+type Option func(*options)
+
+// options holds the configuration of a queue
+type options struct {
+	capacity      int
+	overflow      OverflowPolicy
+	channelBuffer int
+	priority      bool
+	ttl           time.Duration
+}
+
+// defaultOptions returns the configuration used when no option is given
+func defaultOptions() options {
+	return options{
+		channelBuffer: 1,
+	}
+}
+
+// WithCapacity limits the queue to hold at most capacity values
+// A capacity less than or equal to 0 means the queue is unbounded, which is the default
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithOverflowPolicy sets what a bounded queue does when a value is pushed while it is full
+// The default policy is Block
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow = policy
+	}
+}
+
+// WithChannelBuffer sets the buffer size of the channels used to hand values to and from the queue
+// A bigger buffer lets producers and consumers run further ahead of the queue, the default is 1
+// Bounded queues ignore it and always hand values over directly so that the capacity is exact,
+// and priority queues hand values to consumers directly so that urgent values are never held behind others
+func WithChannelBuffer(size int) Option {
+	return func(o *options) {
+		if size >= 0 {
+			o.channelBuffer = size
+		}
+	}
+}
+
+// WithPriority makes the queue poll the values of higher priority first, see Queue.PushPriority
+// Values of equal priority are polled in the order they were pushed
+func WithPriority() Option {
+	return func(o *options) {
+		o.priority = true
+	}
+}
+
+// WithTTL discards the values which were not polled within the ttl, unless they are pushed with their own TTL
+// A ttl less than or equal to 0 means values never expire, which is the default
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// PushOption configures a single push
+type PushOption func(*pushOptions)
+
+// pushOptions holds the configuration of a push
+type pushOptions struct {
+	priority int
+	ttl      time.Duration
+	dropped  *bool
+	requeue  bool
+	deadline *time.Time
+}
+
+// Priority pushes the value with the priority, see Queue.PushPriority
+func Priority(priority int) PushOption {
+	return func(o *pushOptions) {
+		o.priority = priority
+	}
+}
+
+// TTL discards the value if it is not polled within the ttl, overriding the TTL of the queue
+// A ttl less than or equal to 0 means the value never expires
+func TTL(ttl time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.ttl = ttl
+	}
+}
+
+// Dropped sets *dropped to true if the DropNewest policy of a full queue discards the value instead of pushing it
+// A value pushed with PushAfter or PushAt is only admitted once due, its drop is not reported
+func Dropped(dropped *bool) PushOption {
+	return func(o *pushOptions) {
+		o.dropped = dropped
+	}
+}
+
+// Deadline shares the time the value expires through *deadline, which must belong to this single value
+// Once the value is stored, *deadline is set to the time its TTL elapses and left zero if it never expires.
+// A value pushed with *deadline already set expires at *deadline instead of after its TTL,
+// so that a value pushed again, e.g. with Requeue, keeps the time it got when first stored.
+// *deadline may only be read once the value was polled.
+func Deadline(deadline *time.Time) PushOption {
+	return func(o *pushOptions) {
+		o.deadline = deadline
+	}
+}
+
+// Requeue pushes the value even if a bounded queue is full, without waiting nor applying the overflow policy
+// It is meant for values handed back by their consumer, which were already admitted once
+// A queue holds more values than its capacity while such values are requeued
+func Requeue() PushOption {
+	return func(o *pushOptions) {
+		o.requeue = true
+	}
+}
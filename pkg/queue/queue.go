@@ -1,83 +1,744 @@
 package queue
 
 import (
+	"container/heap"
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrClosed is returned when the queue is closed and holds no more values
+	ErrClosed = errors.New("queue: closed")
+
+	// ErrFull is returned by Push when a bounded queue with the Reject policy is full
+	ErrFull = errors.New("queue: full")
+
+	// errDropped is returned by admit when the DropNewest policy discards the entry
+	errDropped = errors.New("queue: dropped")
+)
+
 // queue is a struct for queue
-type queue struct {
-	enqueue chan interface{}
-	dequeue chan interface{}
-	close   chan bool
-	once    sync.Once
+type queue[T any] struct {
+	enqueue  chan entry[T]
+	dequeue  chan entry[T]
+	close    chan bool
+	requests chan func()
+	done     chan struct{}
+
+	// emptied is closed by settle once the manage goroutine exited and the consumers took every value
+	emptied     chan struct{}
+	emptiedOnce sync.Once
+	once        sync.Once
+	forced      sync.Once
+	options     options
+	counters    counters
+
+	// closing is closed first by Close to release the blocked pushes,
+	// writers keeps enqueue from being closed while a push sends to it
+	closing chan struct{}
+	writers sync.RWMutex
+
+	// expiryHandler is set by OnExpiry
+	expiryHandler atomic.Pointer[func(T)]
+
+	// forwarders hand the values to the channels returned by C and CFunc, channel is the one of C
+	// stopping is closed by Shutdown to stop them
+	forwardMu  sync.Mutex
+	forwarders []*forwarder[T]
+	channel    <-chan T
+	stopping   chan struct{}
+	stopOnce   sync.Once
+
+	// items, delayed, timer, seq and writeClosed are owned by the manage goroutine
+	items       buffer[T]
+	delayed     schedule[T]
+	timer       *time.Timer
+	timerDue    time.Time
+	seq         uint64
+	writeClosed bool
+
+	// nextExpiry is no later than the earliest expiry of the items, zero if none expires,
+	// the expiry timer purges the items once it is reached
+	nextExpiry  time.Time
+	expiryTimer *time.Timer
+	expiryDue   time.Time
 }
 
-// Queue is an interface for a queue structure
-type Queue interface {
+// Queue is an interface for a queue structure holding values of type T
+type Queue[T any] interface {
 
 	// Push pushes the value to the end of queue
-	Push(value interface{})
+	// If the queue is bounded and full, the outcome depends on its OverflowPolicy
+	// It returns ErrClosed once the queue is closed, a Push waiting for room returns it as well
+	Push(value T, opts ...PushOption) error
+
+	// TryPush pushes the value to the end of queue without blocking
+	// It returns false if the queue can not accept the value right now or is closed
+	TryPush(value T, opts ...PushOption) bool
+
+	// PushPriority pushes the value with the priority
+	// A queue created with WithPriority polls the values of higher priority first,
+	// other queues ignore the priority and behave like Push
+	PushPriority(value T, priority int) error
+
+	// TryPushPriority works like TryPush, pushing the value with the priority
+	TryPushPriority(value T, priority int) bool
+
+	// PushAfter pushes the value once the delay has elapsed, values due at the same time keep their order
+	// The value is not visible to Poll nor counted by Len until then
+	PushAfter(value T, delay time.Duration, opts ...PushOption) error
+
+	// PushAt pushes the value at the given time, see PushAfter
+	PushAt(value T, at time.Time, opts ...PushOption) error
+
+	// PushContext pushes the value to the end of queue
+	// It returns ctx.Err() if the context is done before the value is accepted
+	PushContext(ctx context.Context, value T, opts ...PushOption) error
+
+	// PushBatch pushes the values to the end of queue in a single handoff, the options apply to every value
+	// A full queue blocking its producers takes the values it can not hold one by one,
+	// with the Reject policy the values following the first rejected one are not pushed
+	PushBatch(values []T, opts ...PushOption) error
+
+	// PushBatchContext works like PushBatch
+	// It returns ctx.Err() if the context is done before every value is accepted, the values before may have been pushed
+	PushBatchContext(ctx context.Context, values []T, opts ...PushOption) error
 
 	// Poll polls the top most value from the queue
 	// If the queue is empty, it will block until a value is available
-	Poll() (value interface{}, ok bool)
+	Poll() (value T, ok bool)
+
+	// PollContext polls the top most value from the queue
+	// It returns ctx.Err() if the context is done before a value is available,
+	// and ErrClosed if the queue is closed
+	PollContext(ctx context.Context) (value T, err error)
+
+	// TryPoll polls the top most value from the queue without blocking
+	// available reports whether a value was returned and ok is false once the queue is closed and drained
+	TryPoll() (value T, ok bool, available bool)
+
+	// PollTimeout polls the top most value from the queue, waiting at most d for one
+	// available reports whether a value was returned and ok is false once the queue is closed and drained
+	PollTimeout(d time.Duration) (value T, ok bool, available bool)
+
+	// PollBatch polls up to max values from the queue in a single handoff
+	// It waits at most wait for the first value and returns the ones available at that time,
+	// an empty batch means no value became available in time or the queue is closed and drained
+	PollBatch(max int, wait time.Duration) []T
+
+	// C returns a channel receiving the values polled from the queue, closed once the queue is closed and drained
+	// Every call returns the same channel, fed by a goroutine started by the first call.
+	// A value waiting for a receiver still counts in Len, it is dropped on a forced close and handed back by Shutdown
+	C() <-chan T
+
+	// Len returns the number of values currently held by the queue
+	Len() int
+
+	// IsClosed reports whether the queue is closed for write operations
+	IsClosed() bool
+
+	// Stats returns a snapshot of the counters of the queue
+	Stats() Stats
+
+	// OnExpiry calls fn with every value discarded because it expired, instead of discarding it silently
+	// The queue discards the expired values it holds without waiting for a poll, fn then runs on a goroutine of its own,
+	// and on the goroutine polling a value found expired. fn may thus be called concurrently.
+	// A later call replaces fn, and a nil fn discards the expired values silently again
+	OnExpiry(fn func(T))
+
+	// Drain closes the queue for write operations and waits until the consumers polled every value, delayed ones included
+	// It returns ctx.Err() if the context is done first, the queue is then left read only
+	Drain(ctx context.Context) error
 
-	// Close closes the queue for write operations
+	// Shutdown drains the queue like Drain, if the context is done first it closes the queue
+	// and returns the values which were not polled along with ctx.Err()
+	// The values held come first in polling order, followed by the delayed ones in due order
+	Shutdown(ctx context.Context) ([]T, error)
+
+	// Close closes the queue for write operations, later pushes return ErrClosed
 	// if the timeOut is less than 0, it will close the channel to enqueue and keep the queue read only
+	// Close may be called several times and concurrently, only the first call has an effect
 	Close(timeOut time.Duration)
 }
 
 // manage is a function to manage the queue
-func (q *queue) manage() {
-	queue := []interface{}{}
+// Every case of its select is disabled while it can not proceed, so an idle queue blocks instead of spinning:
+// enqueue once the queue is write-closed or full, dequeue while the queue is empty and the timers without delayed or expiring values.
+func (q *queue[T]) manage() {
+	defer q.settle()
+	defer close(q.done)
 	defer close(q.dequeue)
+	defer q.stopExpiryTimer()
 
 	// An infinite loop to periodically check the queue
 	for {
-		if len(queue) == 0 {
-			select {
-			case <-q.close:
-				return
-			case v, ok := <-q.enqueue:
-				if !ok {
-					return
-				}
-				queue = append(queue, v)
+		q.purge()
+		q.release()
+		if q.writeClosed && q.items.len() == 0 && len(q.delayed) == 0 {
+			return
+		}
+
+		select {
+		case <-q.close:
+			q.counters.drop(q.items.len())
+			q.discardDelayed()
+			return
+		case fn := <-q.requests:
+			fn()
+		case <-q.wakeup():
+			q.timerDue = time.Time{}
+		case <-q.expiring():
+			q.expiryDue = time.Time{}
+		case e, ok := <-q.accepting():
+			if !ok {
+				// The values held and delayed are still to be delivered
+				q.writeClosed = true
+				continue
+			}
+			q.store(e)
+		case q.sending() <- q.head():
+			q.items.pop()
+		}
+	}
+}
+
+// store adds the entry to items, numbering it so that equal priorities keep their arrival order
+// The time to live of the entry starts once it is stored, unless its deadline was set when it was first stored
+func (q *queue[T]) store(e entry[T]) {
+	q.seq++
+	e.seq = q.seq
+	switch {
+	case e.deadline != nil && !e.deadline.IsZero():
+		e.expires = *e.deadline
+	case e.ttl > 0:
+		e.expires = time.Now().Add(e.ttl)
+		if e.deadline != nil {
+			*e.deadline = e.expires
+		}
+	}
+	if !e.expires.IsZero() && (q.nextExpiry.IsZero() || e.expires.Before(q.nextExpiry)) {
+		q.nextExpiry = e.expires
+	}
+	q.items.push(e)
+}
+
+// purge discards the items whose time to live elapsed, so that they neither take room nor count in the depth
+// The expiry handler is called with them on a goroutine of its own, so that it never blocks the manage goroutine
+func (q *queue[T]) purge() {
+	// Most queues hold no expiring item, they do not need to read the clock
+	if q.nextExpiry.IsZero() {
+		return
+	}
+	now := time.Now()
+	if now.Before(q.nextExpiry) {
+		return
+	}
+
+	// The entry waiting in a buffered dequeue is taken back, so that it is purged along with the items
+	select {
+	case e := <-q.dequeue:
+		q.items.unpop(e)
+	default:
+	}
+
+	handler := q.expiryHandler.Load()
+	var expired []T
+	q.nextExpiry = q.items.purge(now, func(e entry[T]) {
+		q.counters.expire()
+		if handler != nil {
+			expired = append(expired, e.value)
+		}
+	})
+
+	if len(expired) > 0 {
+		go func() {
+			for _, value := range expired {
+				(*handler)(value)
 			}
-		} else {
-			select {
-			case <-q.close:
+		}()
+	}
+}
+
+// expiring returns a channel receiving once the earliest expiry of the items is reached, or nil if none expires
+func (q *queue[T]) expiring() <-chan time.Time {
+	if q.nextExpiry.IsZero() {
+		return nil
+	}
+
+	// The timer is replaced rather than reset, so that no stale tick can be received
+	if q.expiryTimer == nil || !q.nextExpiry.Equal(q.expiryDue) {
+		q.stopExpiryTimer()
+		q.expiryTimer = time.NewTimer(time.Until(q.nextExpiry))
+		q.expiryDue = q.nextExpiry
+	}
+
+	return q.expiryTimer.C
+}
+
+func (q *queue[T]) stopExpiryTimer() {
+	if q.expiryTimer != nil {
+		q.expiryTimer.Stop()
+	}
+}
+
+// release moves the delayed entries which are due to items
+func (q *queue[T]) release() {
+	if len(q.delayed) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for len(q.delayed) > 0 && !q.delayed[0].due.After(now) {
+		// A full queue blocking its producers holds the due entries back as well
+		if q.full() && q.options.overflow == Block {
+			return
+		}
+
+		s := heap.Pop(&q.delayed).(scheduled[T])
+		q.counters.unschedule(1)
+		if err := q.admit(s.entry); err != nil && err != errDropped {
+			q.counters.refused()
+		}
+	}
+}
+
+// wakeup returns a channel receiving once the earliest delayed entry is due, or nil if there is none to wait for
+func (q *queue[T]) wakeup() <-chan time.Time {
+	if len(q.delayed) == 0 || (q.full() && q.options.overflow == Block) {
+		return nil
+	}
+
+	// The timer is replaced rather than reset, so that no stale tick can be received
+	due := q.delayed[0].due
+	if q.timer == nil || !due.Equal(q.timerDue) {
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		q.timer = time.NewTimer(time.Until(due))
+		q.timerDue = due
+	}
+
+	return q.timer.C
+}
+
+// discardDelayed drops the delayed entries on a forced close
+func (q *queue[T]) discardDelayed() {
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+	q.counters.unschedule(len(q.delayed))
+	q.counters.drop(len(q.delayed))
+	q.delayed = nil
+}
+
+// full reports whether a bounded queue has reached its capacity
+func (q *queue[T]) full() bool {
+	return q.options.capacity > 0 && q.items.len() >= q.options.capacity
+}
+
+// accepting returns the enqueue channel, or nil once it is closed and while a full queue blocks its producers
+func (q *queue[T]) accepting() chan entry[T] {
+	if q.writeClosed || q.full() {
+		return nil
+	}
+	return q.enqueue
+}
+
+// sending returns the dequeue channel, or nil while there is no value to hand out
+func (q *queue[T]) sending() chan entry[T] {
+	if q.items.len() == 0 {
+		return nil
+	}
+	return q.dequeue
+}
+
+// head returns the next entry to hand out, or the zero entry if there is none
+func (q *queue[T]) head() entry[T] {
+	if q.items.len() == 0 {
+		return entry[T]{}
+	}
+	return q.items.peek()
+}
+
+// offer adds the entry to items applying the overflow policy unless it is requeued, it must run on the manage goroutine
+func (q *queue[T]) offer(e entry[T], requeue bool) error {
+	// A full queue has not received the closing of enqueue yet
+	if q.IsClosed() {
+		return ErrClosed
+	}
+
+	if requeue {
+		q.store(e)
+		q.counters.pushed()
+		return nil
+	}
+
+	return q.admit(e)
+}
+
+// admit adds the entry to items applying the overflow policy, it must run on the manage goroutine
+func (q *queue[T]) admit(e entry[T]) error {
+	// Expired items make room before the overflow policy applies
+	q.purge()
+	if q.full() {
+		switch q.options.overflow {
+		case DropNewest:
+			q.counters.refused()
+			return errDropped
+		case DropOldest:
+			q.items.evict()
+			q.counters.drop(1)
+		default:
+			return ErrFull
+		}
+	}
+
+	q.store(e)
+	q.counters.pushed()
+	return nil
+}
+
+// blocking reports whether pushes wait on the enqueue channel instead of going through offer
+func (q *queue[T]) blocking() bool {
+	return q.options.capacity <= 0 || q.options.overflow == Block
+}
+
+// absorb moves the values already sent to enqueue into items, so that
+// requests observe every Push that returned before they were made
+func (q *queue[T]) absorb() {
+	for !q.writeClosed && !q.full() {
+		select {
+		case e, ok := <-q.enqueue:
+			if !ok {
+				q.writeClosed = true
 				return
-			case v, ok := <-q.enqueue:
-				if ok {
-					queue = append(queue, v)
-				}
-			case q.dequeue <- queue[0]:
-				queue[0] = nil
-				queue = queue[1:]
 			}
+			q.store(e)
+		default:
+			return
+		}
+	}
+}
+
+// do runs fn on the manage goroutine and waits for it to finish
+// It returns false if the manage goroutine has already exited
+func (q *queue[T]) do(fn func()) bool {
+	finished := make(chan struct{})
+	select {
+	case q.requests <- func() {
+		defer close(finished)
+		q.absorb()
+		fn()
+	}:
+		<-finished
+		return true
+	case <-q.done:
+		return false
+	}
+}
+
+func (q *queue[T]) Push(value T, opts ...PushOption) error {
+	e, o := q.entry(value, opts)
+	if !q.blocking() || o.requeue {
+		return q.push(e, o)
+	}
+
+	return q.send(context.Background(), e)
+}
+
+// send hands the entry to the manage goroutine through enqueue, waiting while the queue is full
+func (q *queue[T]) send(ctx context.Context, e entry[T]) error {
+	q.writers.RLock()
+	defer q.writers.RUnlock()
+
+	if q.IsClosed() {
+		return ErrClosed
+	}
+
+	select {
+	case q.enqueue <- e:
+		q.counters.pushed()
+		return nil
+	case <-q.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *queue[T]) PushPriority(value T, priority int) error {
+	return q.Push(value, Priority(priority))
+}
+
+func (q *queue[T]) PushAfter(value T, delay time.Duration, opts ...PushOption) error {
+	return q.PushAt(value, time.Now().Add(delay), opts...)
+}
+
+func (q *queue[T]) PushAt(value T, at time.Time, opts ...PushOption) error {
+	if !at.After(time.Now()) {
+		return q.Push(value, opts...)
+	}
+
+	e, _ := q.entry(value, opts)
+	err := ErrClosed
+	q.do(func() {
+		if q.IsClosed() {
+			return
+		}
+
+		q.seq++
+		e.seq = q.seq
+		heap.Push(&q.delayed, scheduled[T]{entry: e, due: at})
+		q.counters.schedule(1)
+		err = nil
+	})
+
+	return err
+}
+
+// entry builds the entry of a value from the push options
+func (q *queue[T]) entry(value T, opts []PushOption) (entry[T], pushOptions) {
+	o := pushOptions{ttl: q.options.ttl}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return entry[T]{value: value, priority: o.priority, ttl: o.ttl, deadline: o.deadline}, o
+}
+
+// push hands the entry to offer on the manage goroutine
+func (q *queue[T]) push(e entry[T], o pushOptions) error {
+	err := ErrClosed
+	q.do(func() {
+		err = q.offer(e, o.requeue)
+	})
+
+	if err == errDropped {
+		if o.dropped != nil {
+			*o.dropped = true
+		}
+		return nil
+	}
+
+	return err
+}
+
+func (q *queue[T]) PushContext(ctx context.Context, value T, opts ...PushOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e, o := q.entry(value, opts)
+	if !q.blocking() || o.requeue {
+		return q.push(e, o)
+	}
+
+	return q.send(ctx, e)
+}
+
+func (q *queue[T]) TryPush(value T, opts ...PushOption) bool {
+	e, o := q.entry(value, opts)
+	accepted := false
+	q.do(func() {
+		if q.full() && q.options.overflow == DropNewest && !o.requeue {
+			return
+		}
+		accepted = q.offer(e, o.requeue) == nil
+	})
+
+	return accepted
+}
+
+func (q *queue[T]) TryPushPriority(value T, priority int) bool {
+	return q.TryPush(value, Priority(priority))
+}
+
+// deliverable reports whether the polled entry can be handed to the consumer, counting it as polled
+func (q *queue[T]) deliverable(e entry[T]) bool {
+	if q.expired(e) {
+		return false
+	}
+
+	q.counters.polled(1)
+	q.settle()
+	return true
+}
+
+// settle closes emptied once the manage goroutine exited and the consumers took every value
+// It runs after the manage goroutine exits and after every poll, so that one of them observes the end of the other
+func (q *queue[T]) settle() {
+	select {
+	case <-q.done:
+	default:
+		return
+	}
+
+	if q.Len() == 0 {
+		q.emptiedOnce.Do(func() {
+			close(q.emptied)
+		})
+	}
+}
+
+// expired reports whether the time to live of the polled entry elapsed
+// An expired entry is counted and passed to the expiry handler
+func (q *queue[T]) expired(e entry[T]) bool {
+	if e.expires.IsZero() || time.Now().Before(e.expires) {
+		return false
+	}
+
+	q.counters.expire()
+	q.settle()
+	if handler := q.expiryHandler.Load(); handler != nil {
+		(*handler)(e.value)
+	}
+	return true
+}
+
+func (q *queue[T]) Poll() (T, bool) {
+	for {
+		e, ok := <-q.dequeue
+		if !ok {
+			return e.value, false
+		}
+		if q.deliverable(e) {
+			return e.value, true
+		}
+	}
+}
+
+func (q *queue[T]) PollContext(ctx context.Context) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	for {
+		select {
+		case e, ok := <-q.dequeue:
+			if !ok {
+				return zero, ErrClosed
+			}
+			if q.deliverable(e) {
+				return e.value, nil
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+func (q *queue[T]) TryPoll() (T, bool, bool) {
+	for {
+		e, ok, available := q.tryPoll()
+		if !available || q.deliverable(e) {
+			return e.value, ok, available
+		}
+	}
+}
+
+// tryPoll takes the next entry without blocking nor checking its expiry
+func (q *queue[T]) tryPoll() (entry[T], bool, bool) {
+	select {
+	case e, ok := <-q.dequeue:
+		return e, ok, ok
+	default:
+	}
+
+	var (
+		e         entry[T]
+		available bool
+		closed    bool
+	)
+	running := q.do(func() {
+		// Entries already handed to dequeue are older than the ones in items
+		select {
+		case e = <-q.dequeue:
+			available = true
+			return
+		default:
+		}
+
+		if q.items.len() > 0 {
+			e = q.items.pop()
+			available = true
+			return
+		}
+
+		// absorb may have just received the closing of enqueue, the queue then holds nothing anymore
+		closed = q.writeClosed && len(q.delayed) == 0
+	})
+	if !running {
+		e, ok := <-q.dequeue
+		return e, ok, ok
+	}
+
+	return e, !closed, available
+}
+
+func (q *queue[T]) PollTimeout(d time.Duration) (T, bool, bool) {
+	if d <= 0 {
+		return q.TryPoll()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-q.dequeue:
+			if !ok {
+				return e.value, false, false
+			}
+			if q.deliverable(e) {
+				return e.value, true, true
+			}
+		case <-timer.C:
+			var zero T
+			return zero, true, false
 		}
 	}
 }
 
-func (q *queue) Push(value interface{}) {
-	q.enqueue <- value
+func (q *queue[T]) Len() int {
+	return q.counters.depth()
+}
+
+func (q *queue[T]) IsClosed() bool {
+	return q.counters.closed.Load()
 }
 
-func (q *queue) Poll() (interface{}, bool) {
-	val, ok := <-q.dequeue
-	return val, ok
+func (q *queue[T]) Stats() Stats {
+	return q.counters.snapshot()
 }
 
-func (q *queue) forceClose() {
-	q.close <- true
-	close(q.close)
+func (q *queue[T]) OnExpiry(fn func(T)) {
+	if fn == nil {
+		q.expiryHandler.Store(nil)
+		return
+	}
+	q.expiryHandler.Store(&fn)
+}
+
+func (q *queue[T]) forceClose() {
+	q.forced.Do(func() {
+		close(q.close)
+	})
 }
 
-func (q *queue) Close(timeOut time.Duration) {
+func (q *queue[T]) Close(timeOut time.Duration) {
 	q.once.Do(func() {
+		q.counters.closed.Store(true)
+		close(q.closing)
+
+		// The pushes in progress return once closing is closed
+		q.writers.Lock()
 		close(q.enqueue)
+		q.writers.Unlock()
+
 		if timeOut >= 0 {
 			go func() {
 				time.Sleep(timeOut)
@@ -87,14 +748,45 @@ func (q *queue) Close(timeOut time.Duration) {
 	})
 }
 
-// New creates new instance of queue
-func New() Queue {
-	q := queue{
-		enqueue: make(chan interface{}, 1),
-		dequeue: make(chan interface{}, 1),
-		close:   make(chan bool, 1),
+// New creates new instance of queue holding values of type T
+func New[T any](opts ...Option) Queue[T] {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// A bounded queue hands values to and from the manager directly so that the capacity is exact
+	enqueueBuffer, dequeueBuffer := o.channelBuffer, o.channelBuffer
+	if o.capacity > 0 {
+		enqueueBuffer, dequeueBuffer = 0, 0
+	}
+
+	var items buffer[T] = &fifo[T]{}
+	if o.priority {
+		// A value waiting in dequeue could not be overtaken by a more urgent one
+		dequeueBuffer = 0
+		items = &priorityHeap[T]{}
+	}
+
+	q := queue[T]{
+		options:  o,
+		items:    items,
+		enqueue:  make(chan entry[T], enqueueBuffer),
+		dequeue:  make(chan entry[T], dequeueBuffer),
+		close:    make(chan bool),
+		closing:  make(chan struct{}),
+		requests: make(chan func()),
+		done:     make(chan struct{}),
+		emptied:  make(chan struct{}),
+		stopping: make(chan struct{}),
 	}
 	go q.manage()
 
 	return &q
 }
+
+// NewPriority creates new instance of queue polling the values of higher priority first
+// It is a shorthand for New with the WithPriority option
+func NewPriority[T any](opts ...Option) Queue[T] {
+	return New[T](append(append([]Option{}, opts...), WithPriority())...)
+}
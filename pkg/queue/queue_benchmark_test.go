@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"fmt"
 	"math/rand"
 	"testing"
 )
@@ -13,7 +14,7 @@ import (
 
 func BenchmarkPushWithoutPoll(b *testing.B) {
 
-	queue := New()
+	queue := New[int]()
 	defer queue.Close(0)
 
 	b.ResetTimer()
@@ -27,7 +28,7 @@ func BenchmarkPushWithoutPoll(b *testing.B) {
 
 func BenchmarkBothPushPollWithPrefilledQueue(b *testing.B) {
 
-	queue := New()
+	queue := New[int]()
 
 	defer queue.Close(0)
 
@@ -50,7 +51,7 @@ func BenchmarkBothPushPollWithPrefilledQueue(b *testing.B) {
 
 func BenchmarkPollWithAsyncPublish(b *testing.B) {
 
-	queue := New()
+	queue := New[int]()
 	defer queue.Close(0)
 
 	closeCh := make(chan bool)
@@ -75,3 +76,54 @@ func BenchmarkPollWithAsyncPublish(b *testing.B) {
 
 	closeCh <- true
 }
+
+func benchmarkMove(b *testing.B, batchSize int) {
+	queue := New[int]()
+	defer queue.Close(0)
+
+	values := make([]int, batchSize)
+	for i := range values {
+		values[i] = i
+	}
+
+	b.ResetTimer()
+
+	for moved := 0; moved < b.N; moved += batchSize {
+		if batchSize == 1 {
+			queue.Push(values[0])
+			queue.Poll()
+			continue
+		}
+
+		queue.PushBatch(values)
+		for polled := 0; polled < batchSize; {
+			polled += len(queue.PollBatch(batchSize-polled, -1))
+		}
+	}
+}
+
+func BenchmarkBatch(b *testing.B) {
+	// Each op moves a single value from a producer to a consumer, in batches of the given size
+
+	for _, batchSize := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("Size=%d", batchSize), func(b *testing.B) {
+			benchmarkMove(b, batchSize)
+		})
+	}
+}
+
+func BenchmarkPollReadOnlyQueue(b *testing.B) {
+	// The manager of a write-closed queue must not compete with the readers for the CPU
+
+	queue := New[int]()
+	for i := 0; i < b.N; i++ {
+		queue.Push(i)
+	}
+	queue.Close(-1)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		queue.Poll()
+	}
+}
@@ -1,6 +1,9 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"runtime"
 	"sync"
 	"testing"
@@ -8,7 +11,7 @@ import (
 )
 
 func TestQueue(t *testing.T) {
-	queue := New()
+	queue := New[int]()
 	for i := 1; i <= 5; i++ {
 		queue.Push(i)
 	}
@@ -33,7 +36,7 @@ func TestQueue(t *testing.T) {
 }
 
 func TestPollAsync(t *testing.T) {
-	queue := New()
+	queue := New[int]()
 	wg := sync.WaitGroup{}
 
 	wg.Add(1)
@@ -65,7 +68,7 @@ func TestPollAsync(t *testing.T) {
 }
 
 func TestPushAsync(t *testing.T) {
-	queue := New()
+	queue := New[int]()
 
 	go func() {
 		maxValue := 100
@@ -89,7 +92,7 @@ func TestPushAsync(t *testing.T) {
 }
 
 func TestPushPollSequential(t *testing.T) {
-	queue := New()
+	queue := New[int]()
 
 	maxValue := 100
 	for i := 0; i < maxValue; i++ {
@@ -112,7 +115,7 @@ func TestPushPollSequential(t *testing.T) {
 
 func TestRoutineClose(t *testing.T) {
 	expected := runtime.NumGoroutine()
-	queue := New()
+	queue := New[int]()
 
 	for i := 0; i < 10; i++ {
 		queue.Push(i)
@@ -134,3 +137,1098 @@ func TestRoutineClose(t *testing.T) {
 		t.Errorf("Invalid Number of Goroutines: Expected: %d, Obtained: %d\n", expected, current)
 	}
 }
+
+func TestQueueTypedValues(t *testing.T) {
+	type item struct {
+		id   int
+		name string
+	}
+
+	queue := New[item]()
+	queue.Push(item{id: 1, name: "first"})
+	queue.Push(item{id: 2, name: "second"})
+	queue.Close(-1)
+
+	for _, expected := range []item{{1, "first"}, {2, "second"}} {
+		val, ok := queue.Poll()
+		if !ok {
+			t.Fatalf("No more values to poll, but expected %v\n", expected)
+		}
+		if val != expected {
+			t.Errorf("Invalid Value: Expected: %v, Obtained: %v\n", expected, val)
+		}
+	}
+
+	val, ok := queue.Poll()
+	if ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+	if val != (item{}) {
+		t.Errorf("Poll on closed queue should return zero value, Obtained: %v\n", val)
+	}
+}
+
+func TestPollContextCancel(t *testing.T) {
+	queue := New[int]()
+	defer queue.Close(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := queue.PollContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.DeadlineExceeded, err)
+	}
+
+	queue.Push(1)
+
+	val, err := queue.PollContext(context.Background())
+	if err != nil {
+		t.Fatalf("PollContext on available value should succeed, Obtained: %v\n", err)
+	}
+	if val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+}
+
+func TestPollContextDoesNotLoseValues(t *testing.T) {
+	queue := New[int]()
+
+	maxValue := 100
+	for i := 0; i < maxValue; i++ {
+		queue.Push(i)
+	}
+	queue.Close(-1)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < maxValue; i++ {
+		if _, err := queue.PollContext(cancelled); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Invalid Error: Expected: %v, Obtained: %v\n", context.Canceled, err)
+		}
+
+		val, err := queue.PollContext(context.Background())
+		if err != nil {
+			t.Fatalf("PollContext on available value should succeed, Obtained: %v\n", err)
+		}
+		if val != i {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v\n", i, val)
+		}
+	}
+
+	if _, err := queue.PollContext(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrClosed, err)
+	}
+}
+
+func TestPushContextCancel(t *testing.T) {
+	queue := New[int]()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := queue.PushContext(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.Canceled, err)
+	}
+
+	if err := queue.PushContext(context.Background(), 2); err != nil {
+		t.Errorf("PushContext should succeed, Obtained: %v\n", err)
+	}
+	queue.Close(-1)
+
+	val, ok := queue.Poll()
+	if !ok || val != 2 {
+		t.Errorf("Invalid Value: Expected: 2, Obtained: %v\n", val)
+	}
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+}
+
+func TestTryPoll(t *testing.T) {
+	queue := New[int]()
+
+	if _, ok, available := queue.TryPoll(); !ok || available {
+		t.Errorf("TryPoll on empty queue: Expected: ok=true available=false, Obtained: ok=%v available=%v\n", ok, available)
+	}
+
+	for i := 0; i < 3; i++ {
+		queue.Push(i)
+	}
+
+	for i := 0; i < 3; i++ {
+		val, ok, available := queue.TryPoll()
+		if !ok || !available {
+			t.Fatalf("TryPoll on available value: Expected: ok=true available=true, Obtained: ok=%v available=%v\n", ok, available)
+		}
+		if val != i {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v\n", i, val)
+		}
+	}
+
+	queue.Close(-1)
+
+	if _, ok, available := queue.TryPoll(); ok || available {
+		t.Errorf("TryPoll on closed queue: Expected: ok=false available=false, Obtained: ok=%v available=%v\n", ok, available)
+	}
+}
+
+func TestTryPollRightAfterClose(t *testing.T) {
+	// The manager may not have received the closing of enqueue before TryPoll runs
+	for i := 0; i < 1000; i++ {
+		queue := New[int]()
+		queue.Close(-1)
+
+		if _, ok, available := queue.TryPoll(); ok || available {
+			t.Fatalf("TryPoll on closed queue: Expected: ok=false available=false, Obtained: ok=%v available=%v\n", ok, available)
+		}
+	}
+}
+
+func TestTryPush(t *testing.T) {
+	queue := New[int]()
+
+	if !queue.TryPush(1) {
+		t.Errorf("TryPush on open queue should be True Got False\n")
+	}
+
+	queue.Close(-1)
+
+	if queue.TryPush(2) {
+		t.Errorf("TryPush on closed queue should be False Got True\n")
+	}
+
+	val, ok := queue.Poll()
+	if !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	queue := New[int]()
+
+	start := time.Now()
+	if _, ok, available := queue.PollTimeout(10 * time.Millisecond); !ok || available {
+		t.Errorf("PollTimeout on empty queue: Expected: ok=true available=false, Obtained: ok=%v available=%v\n", ok, available)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("PollTimeout returned before the timeout: %v\n", elapsed)
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		queue.Push(1)
+	}()
+
+	val, ok, available := queue.PollTimeout(time.Second)
+	if !ok || !available || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v (ok=%v available=%v)\n", val, ok, available)
+	}
+
+	queue.Close(-1)
+
+	if _, ok, available := queue.PollTimeout(time.Second); ok || available {
+		t.Errorf("PollTimeout on closed queue: Expected: ok=false available=false, Obtained: ok=%v available=%v\n", ok, available)
+	}
+}
+
+func pollAll(queue Queue[int]) []int {
+	values := []int{}
+	for val, ok, available := queue.TryPoll(); ok && available; val, ok, available = queue.TryPoll() {
+		values = append(values, val)
+	}
+
+	return values
+}
+
+func TestBoundedQueueBlock(t *testing.T) {
+	queue := New[int](WithCapacity(2))
+
+	queue.Push(1)
+	queue.Push(2)
+
+	pushed := make(chan bool)
+	go func() {
+		queue.Push(3)
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatalf("Push on full queue should block\n")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if queue.TryPush(4) {
+		t.Errorf("TryPush on full queue should be False Got True\n")
+	}
+
+	if val, ok := queue.Poll(); !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatalf("Push should be unblocked once a value is polled\n")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := queue.PushContext(ctx, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.DeadlineExceeded, err)
+	}
+
+	if values := pollAll(queue); len(values) != 2 || values[0] != 2 || values[1] != 3 {
+		t.Errorf("Invalid Values: Expected: [2 3], Obtained: %v\n", values)
+	}
+}
+
+func TestBoundedQueueOverflowPolicies(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   OverflowPolicy
+		expected []int
+		err      error
+	}{
+		{name: "DropNewest", policy: DropNewest, expected: []int{1, 2}},
+		{name: "DropOldest", policy: DropOldest, expected: []int{3, 4}},
+		{name: "Reject", policy: Reject, expected: []int{1, 2}, err: ErrFull},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			queue := New[int](WithCapacity(2), WithOverflowPolicy(tc.policy))
+			defer queue.Close(0)
+
+			for i := 1; i <= 4; i++ {
+				err := queue.Push(i)
+				if i <= 2 && err != nil {
+					t.Errorf("Push on queue with room should succeed, Obtained: %v\n", err)
+				}
+				if i > 2 && !errors.Is(err, tc.err) {
+					t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", tc.err, err)
+				}
+			}
+
+			values := pollAll(queue)
+			if len(values) != len(tc.expected) {
+				t.Fatalf("Invalid Values: Expected: %v, Obtained: %v\n", tc.expected, values)
+			}
+			for i := range values {
+				if values[i] != tc.expected[i] {
+					t.Errorf("Invalid Values: Expected: %v, Obtained: %v\n", tc.expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestWithChannelBuffer(t *testing.T) {
+	for _, size := range []int{0, 1, 16} {
+		size := size
+		t.Run(fmt.Sprintf("Buffer=%d", size), func(t *testing.T) {
+			queue := New[int](WithChannelBuffer(size))
+
+			go func() {
+				for i := 0; i < 100; i++ {
+					queue.Push(i)
+				}
+				queue.Close(-1)
+			}()
+
+			lastValue := 0
+			for value, ok := queue.Poll(); ok; value, ok = queue.Poll() {
+				if value != lastValue {
+					t.Errorf("Invalid Value Obtained: Last: %v, Current: %v\n", lastValue, value)
+				}
+				lastValue++
+			}
+
+			if lastValue != 100 {
+				t.Errorf("Invalid Last Value Obtained: %v\n", lastValue)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	queue := New[int](WithCapacity(3), WithOverflowPolicy(DropOldest))
+
+	if stats := queue.Stats(); stats != (Stats{}) {
+		t.Errorf("Invalid Stats on new queue: %+v\n", stats)
+	}
+
+	before := time.Now()
+	for i := 0; i < 5; i++ {
+		queue.Push(i)
+	}
+
+	if length := queue.Len(); length != 3 {
+		t.Errorf("Invalid Len: Expected: 3, Obtained: %d\n", length)
+	}
+
+	queue.Poll()
+
+	stats := queue.Stats()
+	if stats.Enqueued != 5 || stats.Dequeued != 1 || stats.Dropped != 2 {
+		t.Errorf("Invalid Counters: Expected: 5/1/2, Obtained: %d/%d/%d\n", stats.Enqueued, stats.Dequeued, stats.Dropped)
+	}
+	if stats.Depth != 2 || stats.HighWaterMark != 3 {
+		t.Errorf("Invalid Depth: Expected: 2 (high-water 3), Obtained: %d (high-water %d)\n", stats.Depth, stats.HighWaterMark)
+	}
+	if stats.LastPush.Before(before) || stats.LastPoll.Before(stats.LastPush) {
+		t.Errorf("Invalid Times: LastPush: %v, LastPoll: %v\n", stats.LastPush, stats.LastPoll)
+	}
+	if stats.Closed || queue.IsClosed() {
+		t.Errorf("Queue should not be closed\n")
+	}
+
+	queue.Close(-1)
+
+	if !queue.IsClosed() || !queue.Stats().Closed {
+		t.Errorf("Queue should be closed\n")
+	}
+
+	pollAll(queue)
+	if length := queue.Len(); length != 0 {
+		t.Errorf("Invalid Len: Expected: 0, Obtained: %d\n", length)
+	}
+
+	dropping := New[int](WithCapacity(2), WithOverflowPolicy(DropNewest))
+	for i := 0; i < 3; i++ {
+		dropping.Push(i)
+	}
+	if stats := dropping.Stats(); stats.Depth != 2 || stats.HighWaterMark != 2 || stats.Dropped != 1 {
+		t.Errorf("Invalid Stats: Expected: depth 2, high-water 2, dropped 1, Obtained: %+v\n", stats)
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	queue := NewPriority[string]()
+
+	queue.Push("low-1")
+	queue.PushPriority("high-1", 10)
+	queue.PushPriority("medium", 5)
+	queue.Push("low-2")
+	queue.PushPriority("high-2", 10)
+	queue.Close(-1)
+
+	expected := []string{"high-1", "high-2", "medium", "low-1", "low-2"}
+	for _, e := range expected {
+		val, ok := queue.Poll()
+		if !ok {
+			t.Fatalf("No more values to poll, but expected %s\n", e)
+		}
+		if val != e {
+			t.Errorf("Invalid Value: Expected: %s, Obtained: %v\n", e, val)
+		}
+	}
+
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+}
+
+func TestPriorityQueueDropOldest(t *testing.T) {
+	queue := NewPriority[int](WithCapacity(3), WithOverflowPolicy(DropOldest))
+	defer queue.Close(0)
+
+	queue.PushPriority(1, 1)
+	queue.PushPriority(2, 0)
+	queue.PushPriority(3, 0)
+	queue.PushPriority(4, 2)
+
+	// The oldest value of the lowest priority is the one discarded
+	if values := pollAll(queue); len(values) != 3 || values[0] != 4 || values[1] != 1 || values[2] != 3 {
+		t.Errorf("Invalid Values: Expected: [4 1 3], Obtained: %v\n", values)
+	}
+}
+
+func TestPushPriorityOnFIFOQueue(t *testing.T) {
+	queue := New[int]()
+	defer queue.Close(0)
+
+	queue.PushPriority(1, 0)
+	queue.PushPriority(2, 10)
+	if !queue.TryPushPriority(3, 20) {
+		t.Errorf("TryPushPriority on open queue should be True Got False\n")
+	}
+
+	if values := pollAll(queue); len(values) != 3 || values[0] != 1 || values[1] != 2 || values[2] != 3 {
+		t.Errorf("Invalid Values: Expected: [1 2 3], Obtained: %v\n", values)
+	}
+}
+
+func TestPushAfter(t *testing.T) {
+	queue := New[int]()
+
+	start := time.Now()
+	queue.PushAfter(3, 30*time.Millisecond)
+	queue.PushAfter(1, 10*time.Millisecond)
+	queue.PushAt(2, start.Add(20*time.Millisecond))
+	queue.PushAfter(0, 0)
+
+	if length := queue.Len(); length != 1 {
+		t.Errorf("Invalid Len: Expected: 1, Obtained: %d\n", length)
+	}
+	if scheduled := queue.Stats().Scheduled; scheduled != 3 {
+		t.Errorf("Invalid Scheduled: Expected: 3, Obtained: %d\n", scheduled)
+	}
+
+	// Delayed values are still delivered after the queue is closed for writes
+	queue.Close(-1)
+
+	if err := queue.PushAfter(4, time.Millisecond); !errors.Is(err, ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrClosed, err)
+	}
+
+	for expected := 0; expected <= 3; expected++ {
+		val, ok := queue.Poll()
+		if !ok {
+			t.Fatalf("No more values to poll, but expected %d\n", expected)
+		}
+		if val != expected {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v\n", expected, val)
+		}
+		if due := time.Duration(expected) * 10 * time.Millisecond; time.Since(start) < due {
+			t.Errorf("Value %d polled before its delay of %v\n", val, due)
+		}
+	}
+
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+}
+
+func TestPushAfterDiscardedOnForcedClose(t *testing.T) {
+	queue := New[int]()
+
+	queue.PushAfter(1, time.Hour)
+	queue.Close(0)
+
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+	if stats := queue.Stats(); stats.Scheduled != 0 || stats.Dropped != 1 {
+		t.Errorf("Invalid Stats: Expected: 0 scheduled and 1 dropped, Obtained: %+v\n", stats)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	expired := make(chan int, 2)
+	queue := New[int](WithTTL(10 * time.Millisecond))
+	queue.OnExpiry(func(value int) {
+		expired <- value
+	})
+
+	queue.Push(1)
+	queue.Push(2, TTL(time.Hour))
+	queue.Push(3)
+	time.Sleep(20 * time.Millisecond)
+	queue.Push(4)
+
+	for _, expected := range []int{2, 4} {
+		val, ok := queue.Poll()
+		if !ok {
+			t.Fatalf("No more values to poll, but expected %d\n", expected)
+		}
+		if val != expected {
+			t.Errorf("Invalid Value: Expected: %d, Obtained: %v\n", expected, val)
+		}
+	}
+
+	// The values are purged by the queue while Poll may find one expired, so the handler calls are not ordered
+	if first, second := <-expired, <-expired; first+second != 4 || first*second != 3 {
+		t.Errorf("Invalid Expired Values: Expected: 1 and 3, Obtained: %d and %d\n", first, second)
+	}
+	if stats := queue.Stats(); stats.Expired != 2 || stats.Dequeued != 2 || stats.Depth != 0 {
+		t.Errorf("Invalid Stats: Expected: 2 expired, 2 dequeued and a depth of 0, Obtained: %+v\n", stats)
+	}
+}
+
+func TestTTLPurgedWithoutPoll(t *testing.T) {
+	expired := make(chan int, 2)
+	queue := New[int](WithCapacity(2), WithOverflowPolicy(DropNewest), WithTTL(10*time.Millisecond))
+	queue.OnExpiry(func(value int) {
+		expired <- value
+	})
+
+	queue.Push(1)
+	queue.Push(2)
+	queue.Push(3)
+
+	time.Sleep(30 * time.Millisecond)
+	if length := queue.Len(); length != 0 {
+		t.Errorf("Invalid Len: Expected: 0, Obtained: %d\n", length)
+	}
+
+	var dropped bool
+	if err := queue.Push(4, TTL(time.Hour), Dropped(&dropped)); err != nil || dropped {
+		t.Errorf("Invalid Push: Expected: <nil> and not dropped, Obtained: %v and %v\n", err, dropped)
+	}
+	if first, second := <-expired, <-expired; first+second != 3 {
+		t.Errorf("Invalid Expired Values: Expected: 1 and 2, Obtained: %d and %d\n", first, second)
+	}
+	if val, _, available := queue.TryPoll(); !available || val != 4 {
+		t.Errorf("Invalid Value: Expected: 4, Obtained: %v\n", val)
+	}
+	if stats := queue.Stats(); stats.Expired != 2 || stats.Dropped != 1 {
+		t.Errorf("Invalid Stats: Expected: 2 expired and 1 dropped, Obtained: %+v\n", stats)
+	}
+}
+
+func TestTTLPurgedFromChannelBuffer(t *testing.T) {
+	queue := New[int](WithChannelBuffer(1), WithTTL(time.Millisecond))
+
+	queue.Push(1)
+	queue.Push(2)
+	time.Sleep(20 * time.Millisecond)
+
+	if stats := queue.Stats(); stats.Depth != 0 || stats.Expired != 2 {
+		t.Errorf("Invalid Stats: Expected: 2 expired and a depth of 0, Obtained: %+v\n", stats)
+	}
+}
+
+func TestTTLPurgedBeforeOverflow(t *testing.T) {
+	queue := New[int](WithCapacity(2), WithOverflowPolicy(Reject))
+
+	queue.Push(1, TTL(time.Millisecond))
+	queue.Push(2)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := queue.Push(3); err != nil {
+		t.Errorf("Invalid Error: Expected: <nil>, Obtained: %v\n", err)
+	}
+	if values := pollAll(queue); fmt.Sprint(values) != "[2 3]" {
+		t.Errorf("Invalid Values: Expected: [2 3], Obtained: %v\n", values)
+	}
+}
+
+func TestTTLStartsOnceDelayed(t *testing.T) {
+	queue := New[int]()
+
+	queue.PushAfter(1, 20*time.Millisecond, TTL(time.Hour))
+	queue.PushAfter(2, 20*time.Millisecond, TTL(time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+
+	if val, _, available := queue.PollTimeout(time.Second); !available || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if val, _, available := queue.TryPoll(); available {
+		t.Errorf("Expired value should not be polled, Obtained: %v\n", val)
+	}
+	if expired := queue.Stats().Expired; expired != 1 {
+		t.Errorf("Invalid Expired: Expected: 1, Obtained: %d\n", expired)
+	}
+}
+
+func TestPushDropped(t *testing.T) {
+	queue := New[int](WithCapacity(1), WithOverflowPolicy(DropNewest))
+	defer queue.Close(0)
+
+	var dropped bool
+	if err := queue.Push(1, Dropped(&dropped)); err != nil || dropped {
+		t.Errorf("Invalid Push: Expected: <nil> and not dropped, Obtained: %v and %v\n", err, dropped)
+	}
+	if err := queue.Push(2, Dropped(&dropped)); err != nil || !dropped {
+		t.Errorf("Invalid Push: Expected: <nil> and dropped, Obtained: %v and %v\n", err, dropped)
+	}
+}
+
+func TestPushBatchPollBatch(t *testing.T) {
+	queue := New[int]()
+
+	if err := queue.PushBatch([]int{1, 2, 3, 4, 5}); err != nil {
+		t.Errorf("Invalid Error: Expected: <nil>, Obtained: %v\n", err)
+	}
+	if length := queue.Len(); length != 5 {
+		t.Errorf("Invalid Len: Expected: 5, Obtained: %d\n", length)
+	}
+
+	if batch := queue.PollBatch(3, 0); len(batch) != 3 || batch[0] != 1 || batch[1] != 2 || batch[2] != 3 {
+		t.Errorf("Invalid Batch: Expected: [1 2 3], Obtained: %v\n", batch)
+	}
+	if batch := queue.PollBatch(3, time.Second); len(batch) != 2 || batch[0] != 4 || batch[1] != 5 {
+		t.Errorf("Invalid Batch: Expected: [4 5], Obtained: %v\n", batch)
+	}
+	if batch := queue.PollBatch(3, 10*time.Millisecond); len(batch) != 0 {
+		t.Errorf("Invalid Batch: Expected: [], Obtained: %v\n", batch)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		queue.PushBatch([]int{6, 7})
+	}()
+	if batch := queue.PollBatch(3, time.Second); len(batch) == 0 || batch[0] != 6 {
+		t.Errorf("Invalid Batch: Expected to start with 6, Obtained: %v\n", batch)
+	}
+
+	if stats := queue.Stats(); stats.Enqueued != 7 {
+		t.Errorf("Invalid Enqueued: Expected: 7, Obtained: %d\n", stats.Enqueued)
+	}
+
+	queue.Close(-1)
+	if err := queue.PushBatch([]int{8}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrClosed, err)
+	}
+}
+
+func TestPushBatchBounded(t *testing.T) {
+	blocking := New[int](WithCapacity(2))
+	defer blocking.Close(0)
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- blocking.PushBatch([]int{1, 2, 3})
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatalf("PushBatch should block until the full queue has room\n")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if batch := blocking.PollBatch(2, 0); len(batch) != 2 || batch[0] != 1 || batch[1] != 2 {
+		t.Errorf("Invalid Batch: Expected: [1 2], Obtained: %v\n", batch)
+	}
+	if err := <-pushed; err != nil {
+		t.Errorf("Invalid Error: Expected: <nil>, Obtained: %v\n", err)
+	}
+	if val, _, _ := blocking.PollTimeout(time.Second); val != 3 {
+		t.Errorf("Invalid Value: Expected: 3, Obtained: %v\n", val)
+	}
+
+	reject := New[int](WithCapacity(2), WithOverflowPolicy(Reject))
+	defer reject.Close(0)
+
+	if err := reject.PushBatch([]int{1, 2, 3, 4}); !errors.Is(err, ErrFull) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrFull, err)
+	}
+	if values := pollAll(reject); len(values) != 2 || values[0] != 1 || values[1] != 2 {
+		t.Errorf("Invalid Values: Expected: [1 2], Obtained: %v\n", values)
+	}
+
+	dropOldest := New[int](WithCapacity(2), WithOverflowPolicy(DropOldest))
+	defer dropOldest.Close(0)
+
+	dropOldest.PushBatch([]int{1, 2, 3, 4})
+	if values := pollAll(dropOldest); len(values) != 2 || values[0] != 3 || values[1] != 4 {
+		t.Errorf("Invalid Values: Expected: [3 4], Obtained: %v\n", values)
+	}
+}
+
+func TestPollBatchSkipsExpired(t *testing.T) {
+	queue := New[int]()
+	defer queue.Close(0)
+
+	queue.Push(1)
+	queue.PushBatch([]int{2, 3}, TTL(time.Millisecond))
+	queue.Push(4)
+	time.Sleep(5 * time.Millisecond)
+
+	if batch := queue.PollBatch(10, 0); len(batch) != 2 || batch[0] != 1 || batch[1] != 4 {
+		t.Errorf("Invalid Batch: Expected: [1 4], Obtained: %v\n", batch)
+	}
+}
+
+func TestPushAfterClose(t *testing.T) {
+	queues := map[string]Queue[int]{
+		"Unbounded":  New[int](),
+		"Block":      New[int](WithCapacity(1)),
+		"DropNewest": New[int](WithCapacity(1), WithOverflowPolicy(DropNewest)),
+		"Reject":     New[int](WithCapacity(1), WithOverflowPolicy(Reject)),
+		"DropOldest": New[int](WithCapacity(1), WithOverflowPolicy(DropOldest)),
+		"Priority":   NewPriority[int](),
+	}
+
+	for name, queue := range queues {
+		queue.Close(-1)
+		queue.Close(0)
+
+		assertPushesClosed(t, name, queue)
+	}
+
+	// A full queue has not received the close yet when the pushes arrive
+	full := map[string]Queue[int]{
+		"Block":      New[int](WithCapacity(2)),
+		"DropNewest": New[int](WithCapacity(2), WithOverflowPolicy(DropNewest)),
+		"Reject":     New[int](WithCapacity(2), WithOverflowPolicy(Reject)),
+		"DropOldest": New[int](WithCapacity(2), WithOverflowPolicy(DropOldest)),
+	}
+
+	for name, queue := range full {
+		queue.Push(1)
+		queue.Push(2)
+		queue.Close(-1)
+
+		assertPushesClosed(t, name, queue)
+
+		// The values held before the close are left untouched
+		if values := pollAll(queue); len(values) != 2 || values[0] != 1 || values[1] != 2 {
+			t.Errorf("%s: Invalid Values: Expected: [1 2], Obtained: %v\n", name, values)
+		}
+	}
+}
+
+// assertPushesClosed checks that every kind of push to the closed queue fails
+func assertPushesClosed(t *testing.T, name string, queue Queue[int]) {
+	t.Helper()
+
+	if err := queue.Push(4); !errors.Is(err, ErrClosed) {
+		t.Errorf("%s: Invalid Push Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+	}
+	if err := queue.Push(4, Requeue()); !errors.Is(err, ErrClosed) {
+		t.Errorf("%s: Invalid Requeue Push Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+	}
+	if err := queue.PushContext(context.Background(), 4); !errors.Is(err, ErrClosed) {
+		t.Errorf("%s: Invalid PushContext Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+	}
+	if err := queue.PushBatch([]int{4, 5}); !errors.Is(err, ErrClosed) {
+		t.Errorf("%s: Invalid PushBatch Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+	}
+	if err := queue.PushAt(4, time.Now().Add(time.Hour)); !errors.Is(err, ErrClosed) {
+		t.Errorf("%s: Invalid PushAt Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+	}
+	if queue.TryPush(4) {
+		t.Errorf("%s: TryPush on closed queue should be False Got True\n", name)
+	}
+	if queue.TryPush(4, Requeue()) {
+		t.Errorf("%s: Requeue TryPush on closed queue should be False Got True\n", name)
+	}
+}
+
+func TestCloseReleasesBlockedPush(t *testing.T) {
+	queue := New[int](WithCapacity(1))
+	queue.Push(1)
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- queue.Push(2)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	queue.Close(-1)
+
+	select {
+	case err := <-pushed:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close should release the blocked Push\n")
+	}
+
+	if val, ok := queue.Poll(); !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+}
+
+func TestForceCloseTwice(t *testing.T) {
+	q := New[int]().(*queue[int])
+	q.Close(-1)
+
+	q.forceClose()
+	q.forceClose()
+
+	<-q.done
+}
+
+func TestConcurrentPushAndClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		var opts []Option
+		switch i % 3 {
+		case 1:
+			opts = append(opts, WithCapacity(2))
+		case 2:
+			opts = append(opts, WithCapacity(2), WithOverflowPolicy(DropOldest))
+		}
+		queue := New[int](opts...)
+
+		var wg sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			p := p
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := 0; n < 50; n++ {
+					switch (p + n) % 5 {
+					case 0:
+						queue.Push(n)
+					case 1:
+						queue.TryPush(n)
+					case 2:
+						queue.PushBatch([]int{n, n})
+					case 3:
+						queue.PushAfter(n, time.Millisecond)
+					default:
+						ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+						queue.PushContext(ctx, n)
+						cancel()
+					}
+				}
+			}()
+		}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for _, ok, _ := queue.TryPoll(); ok; _, ok, _ = queue.PollTimeout(time.Millisecond) {
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			queue.Close(time.Duration(i%3) * time.Millisecond)
+			queue.Close(0)
+		}()
+
+		wg.Wait()
+	}
+}
+
+func TestDrain(t *testing.T) {
+	queue := New[int]()
+	for i := 0; i < 5; i++ {
+		queue.Push(i)
+	}
+	queue.PushAfter(5, 10*time.Millisecond)
+
+	polled := make(chan []int)
+	go func() {
+		var values []int
+		for val, ok := queue.Poll(); ok; val, ok = queue.Poll() {
+			values = append(values, val)
+		}
+		polled <- values
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := queue.Drain(ctx); err != nil {
+		t.Errorf("Invalid Error: Expected: <nil>, Obtained: %v\n", err)
+	}
+	if length := queue.Len(); length != 0 {
+		t.Errorf("Invalid Len: Expected: 0, Obtained: %d\n", length)
+	}
+	if err := queue.Push(6); !errors.Is(err, ErrClosed) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", ErrClosed, err)
+	}
+	if values := <-polled; len(values) != 6 {
+		t.Errorf("Invalid Values: Expected: [0 1 2 3 4 5], Obtained: %v\n", values)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	queue := New[int]()
+	queue.Push(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := queue.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.DeadlineExceeded, err)
+	}
+
+	// The queue is left read only
+	if val, ok := queue.Poll(); !ok || val != 1 {
+		t.Errorf("Invalid Value: Expected: 1, Obtained: %v\n", val)
+	}
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on closed queue should be False Got True\n")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	queue := New[int]()
+	for i := 0; i < 5; i++ {
+		queue.Push(i)
+	}
+	queue.PushAfter(6, time.Hour)
+	queue.PushAfter(5, time.Minute)
+	queue.Poll()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	leftovers, err := queue.Shutdown(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.Canceled, err)
+	}
+	expected := []int{1, 2, 3, 4, 5, 6}
+	if fmt.Sprint(leftovers) != fmt.Sprint(expected) {
+		t.Errorf("Invalid Leftovers: Expected: %v, Obtained: %v\n", expected, leftovers)
+	}
+
+	if _, ok := queue.Poll(); ok {
+		t.Errorf("Poll on shut down queue should be False Got True\n")
+	}
+	if stats := queue.Stats(); stats.Depth != 0 || stats.Scheduled != 0 || stats.Dropped != 0 {
+		t.Errorf("Invalid Stats: Expected: no depth, scheduled nor dropped values, Obtained: %+v\n", stats)
+	}
+}
+
+func TestShutdownDrained(t *testing.T) {
+	queue := NewPriority[int]()
+	queue.Push(1)
+	queue.Poll()
+
+	leftovers, err := queue.Shutdown(context.Background())
+	if err != nil || leftovers != nil {
+		t.Errorf("Invalid Shutdown: Expected: [] <nil>, Obtained: %v %v\n", leftovers, err)
+	}
+}
+
+func TestChan(t *testing.T) {
+	queue := New[int]()
+	if queue.C() != queue.C() {
+		t.Error("C should return the same channel\n")
+	}
+
+	for i := 0; i < 3; i++ {
+		queue.Push(i)
+	}
+	queue.Close(-1)
+
+	var values []int
+	for val := range queue.C() {
+		values = append(values, val)
+	}
+	if fmt.Sprint(values) != "[0 1 2]" {
+		t.Errorf("Invalid Values: Expected: [0 1 2], Obtained: %v\n", values)
+	}
+}
+
+func TestChanSelect(t *testing.T) {
+	queue := New[int]()
+	defer queue.Close(-1)
+
+	select {
+	case val := <-queue.C():
+		t.Fatalf("Invalid Receive: Expected: nothing, Obtained: %d\n", val)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	queue.Push(1)
+	select {
+	case val, ok := <-queue.C():
+		if !ok || val != 1 {
+			t.Errorf("Invalid Receive: Expected: 1 true, Obtained: %d %v\n", val, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Value should be received from the channel\n")
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	for name, policy := range map[string]OverflowPolicy{"Block": Block, "Reject": Reject, "DropNewest": DropNewest} {
+		queue := New[int](WithCapacity(1), WithOverflowPolicy(policy))
+		queue.Push(1)
+
+		if err := queue.Push(2, Requeue()); err != nil {
+			t.Errorf("%s: Invalid Error: Expected: <nil>, Obtained: %v\n", name, err)
+		}
+		if length := queue.Len(); length != 2 {
+			t.Errorf("%s: Invalid Len: Expected: 2, Obtained: %d\n", name, length)
+		}
+		if !queue.TryPush(3, Requeue()) {
+			t.Errorf("%s: TryPush with Requeue on full queue should be True Got False\n", name)
+		}
+
+		queue.Close(-1)
+		if values := pollAll(queue); fmt.Sprint(values) != "[1 2 3]" {
+			t.Errorf("%s: Invalid Values: Expected: [1 2 3], Obtained: %v\n", name, values)
+		}
+		if err := queue.Push(4, Requeue()); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s: Invalid Error: Expected: %v, Obtained: %v\n", name, ErrClosed, err)
+		}
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	queue := New[int](WithTTL(20 * time.Millisecond))
+	defer queue.Close(0)
+
+	var deadline, never time.Time
+	before := time.Now()
+	queue.Push(1, Deadline(&deadline))
+	queue.Push(2, TTL(-1), Deadline(&never))
+	queue.Poll()
+	queue.Poll()
+
+	if deadline.Before(before.Add(20*time.Millisecond)) || deadline.After(time.Now().Add(20*time.Millisecond)) {
+		t.Fatalf("Invalid Deadline: Expected: 20ms after the push, Obtained: %v after\n", deadline.Sub(before))
+	}
+	if !never.IsZero() {
+		t.Errorf("Invalid Deadline: Expected: zero, Obtained: %v\n", never)
+	}
+
+	// Pushed again, the value keeps its deadline instead of getting a new TTL
+	time.Sleep(10 * time.Millisecond)
+	queue.Push(1, Deadline(&deadline))
+	time.Sleep(time.Until(deadline) + 5*time.Millisecond)
+
+	if _, _, available := queue.TryPoll(); available {
+		t.Errorf("The value should expire at its deadline\n")
+	}
+	if expired := queue.Stats().Expired; expired != 1 {
+		t.Errorf("Invalid Expired: Expected: 1, Obtained: %d\n", expired)
+	}
+}
+
+func TestChanForcedClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	queues := make([]Queue[int], 100)
+	for i := range queues {
+		queues[i] = New[int]()
+		queues[i].Push(1)
+		queues[i].Push(2)
+		<-queues[i].C()
+		queues[i].Close(0)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Invalid Goroutines: Expected: at most %d, Obtained: %d\n", before, after)
+	}
+
+	if stats := queues[0].Stats(); stats.Dequeued != 1 || stats.Dropped != 1 || stats.Depth != 0 {
+		t.Errorf("Invalid Stats: Expected: 1 dequeued, 1 dropped and a depth of 0, Obtained: %+v\n", stats)
+	}
+}
+
+func TestChanShutdown(t *testing.T) {
+	queue := New[int]()
+	queue.Push(1)
+	queue.Push(2)
+
+	values := CFunc(queue, func(value int) string {
+		return fmt.Sprint(value)
+	})
+
+	// Let the forwarding goroutine take the first value
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	leftovers, err := queue.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Invalid Error: Expected: %v, Obtained: %v\n", context.DeadlineExceeded, err)
+	}
+	if fmt.Sprint(leftovers) != "[1 2]" {
+		t.Errorf("Invalid Leftovers: Expected: [1 2], Obtained: %v\n", leftovers)
+	}
+	if _, ok := <-values; ok {
+		t.Errorf("Channel should be closed once the queue is shut down\n")
+	}
+}
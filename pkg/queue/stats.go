@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the counters of a queue
+type Stats struct {
+	// Enqueued is the number of values pushed to the queue
+	Enqueued uint64
+
+	// Dequeued is the number of values polled from the queue
+	Dequeued uint64
+
+	// Dropped is the number of values discarded by the overflow policy or by a forced close
+	Dropped uint64
+
+	// Expired is the number of values discarded because their time to live elapsed
+	Expired uint64
+
+	// Depth is the number of values currently held by the queue
+	Depth int
+
+	// HighWaterMark is the highest Depth the queue has reached
+	HighWaterMark int
+
+	// Scheduled is the number of values pushed with a delay which are not due yet
+	Scheduled int
+
+	// LastPush is the time of the last push, zero if nothing was pushed yet
+	LastPush time.Time
+
+	// LastPoll is the time of the last poll, zero if nothing was polled yet
+	LastPoll time.Time
+
+	// Closed reports whether the queue is closed for write operations
+	Closed bool
+}
+
+// counters tracks the statistics of a queue, it is safe for concurrent use
+type counters struct {
+	enqueued      atomic.Uint64
+	dequeued      atomic.Uint64
+	dropped       atomic.Uint64
+	expired       atomic.Uint64
+	highWaterMark atomic.Int64
+	scheduled     atomic.Int64
+	lastPush      atomic.Int64
+	lastPoll      atomic.Int64
+	closed        atomic.Bool
+}
+
+// depth returns the number of values pushed but neither polled, dropped nor expired
+func (c *counters) depth() int {
+	// Load the outgoing counters first, so that a concurrent poll can not make the depth negative
+	expired := c.expired.Load()
+	dropped := c.dropped.Load()
+	dequeued := c.dequeued.Load()
+	enqueued := c.enqueued.Load()
+	if enqueued < dequeued+dropped+expired {
+		return 0
+	}
+
+	return int(enqueued - dequeued - dropped - expired)
+}
+
+func (c *counters) pushed() {
+	c.enqueued.Add(1)
+	c.lastPush.Store(time.Now().UnixNano())
+
+	depth := int64(c.depth())
+	for {
+		mark := c.highWaterMark.Load()
+		if depth <= mark || c.highWaterMark.CompareAndSwap(mark, depth) {
+			return
+		}
+	}
+}
+
+// refused counts a value pushed and discarded at once, it never raises the high water mark
+func (c *counters) refused() {
+	// Count the drop first, so that a concurrent push does not observe the value in the depth
+	c.dropped.Add(1)
+	c.enqueued.Add(1)
+	c.lastPush.Store(time.Now().UnixNano())
+}
+
+func (c *counters) polled(n int) {
+	c.dequeued.Add(uint64(n))
+	c.lastPoll.Store(time.Now().UnixNano())
+}
+
+func (c *counters) drop(n int) {
+	c.dropped.Add(uint64(n))
+}
+
+func (c *counters) expire() {
+	c.expired.Add(1)
+}
+
+func (c *counters) schedule(n int) {
+	c.scheduled.Add(int64(n))
+}
+
+func (c *counters) unschedule(n int) {
+	c.scheduled.Add(-int64(n))
+}
+
+func (c *counters) snapshot() Stats {
+	stats := Stats{
+		Enqueued:      c.enqueued.Load(),
+		Dequeued:      c.dequeued.Load(),
+		Dropped:       c.dropped.Load(),
+		Expired:       c.expired.Load(),
+		Depth:         c.depth(),
+		HighWaterMark: int(c.highWaterMark.Load()),
+		Scheduled:     int(c.scheduled.Load()),
+		Closed:        c.closed.Load(),
+	}
+	if nano := c.lastPush.Load(); nano != 0 {
+		stats.LastPush = time.Unix(0, nano)
+	}
+	if nano := c.lastPoll.Load(); nano != 0 {
+		stats.LastPoll = time.Unix(0, nano)
+	}
+
+	return stats
+}